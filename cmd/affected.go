@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar"
+	"github.com/gruntwork-io/terragrunt/util"
+)
+
+// Presets accepted by `--autoplan-mode`. Without one, `--autoplan` and the `atlantis_autoplan` locals decide.
+var autoplanModes = []string{"off", "on", "changed"}
+
+// Checks that `--autoplan-mode` names a known preset, and that `changed` knows which files changed
+func validateAutoplanMode() error {
+	if autoplanMode != "" && !util.ListContainsElement(autoplanModes, autoplanMode) {
+		return fmt.Errorf("unknown --autoplan-mode value %q, must be one of %s", autoplanMode, strings.Join(autoplanModes, ", "))
+	}
+	if autoplanMode == "changed" && changedFilesFrom == "" {
+		return fmt.Errorf("--autoplan-mode=changed requires --changed-files-from")
+	}
+
+	return nil
+}
+
+// Sets autoplan of every project from `--autoplan-mode`, overriding `--autoplan` and the `atlantis_autoplan` locals.
+// With `changed`, only the projects affected by the files listed in `--changed-files-from` are autoplanned.
+func applyAutoplanMode(projects []AtlantisProject) error {
+	if autoplanMode == "" {
+		return nil
+	}
+
+	changedFiles := []string{}
+	if autoplanMode == "changed" {
+		// The changed files are listed like the patterns of `--filter-file`
+		files, err := readFilterFile(changedFilesFrom)
+		if err != nil {
+			return fmt.Errorf("could not read --changed-files-from: %w", err)
+		}
+		changedFiles = files
+	}
+
+	for i := range projects {
+		switch autoplanMode {
+		case "off":
+			projects[i].Autoplan.Enabled = false
+		case "on":
+			projects[i].Autoplan.Enabled = true
+		case "changed":
+			projects[i].Autoplan.Enabled = isProjectAffected(projects[i], changedFiles)
+		}
+	}
+	return nil
+}
+
+// AffectedProjects returns the projects of cfg whose `when_modified` globs match any of changedFiles. The changed files
+// are relative to the repo root, while the globs are relative to each project's dir, the same way Atlantis reads them.
+func AffectedProjects(cfg *AtlantisConfig, changedFiles []string) []AtlantisProject {
+	affected := []AtlantisProject{}
+	for _, project := range cfg.Projects {
+		if isProjectAffected(project, changedFiles) {
+			affected = append(affected, project)
+		}
+	}
+	return affected
+}
+
+// Checks if any of changedFiles is matched by the `when_modified` of the project. Like in Atlantis, the entries apply in
+// order, so a negated entry only excludes the files matched by the entries before it.
+func isProjectAffected(project AtlantisProject, changedFiles []string) bool {
+	for _, changedFile := range changedFiles {
+		changedFile = path.Clean(filepath.ToSlash(changedFile))
+		matched := false
+		for _, whenModified := range project.Autoplan.WhenModified {
+			negation, glob := splitNegation(whenModified)
+			if entryMatched, err := doublestar.Match(path.Join(project.Dir, glob), changedFile); err == nil && entryMatched {
+				matched = negation == ""
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
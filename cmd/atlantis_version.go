@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// A project field that older Atlantis versions do not know, and so refuse to load
+type atlantisFeature struct {
+	// The key of the field in atlantis.yaml
+	field string
+
+	// The first Atlantis version supporting the field
+	since [3]int
+
+	// Removes the field from the project, returning if it was set
+	drop func(project *AtlantisProject) bool
+}
+
+// Project fields emitted by this tool that need a recent Atlantis, checked by `--atlantis-version`
+var atlantisFeatures = []atlantisFeature{
+	{
+		field: "plan_requirements",
+		since: [3]int{0, 23, 0},
+		drop: func(project *AtlantisProject) bool {
+			set := project.PlanRequirements != nil
+			project.PlanRequirements = nil
+			return set
+		},
+	},
+	{
+		field: "execution_order_group",
+		since: [3]int{0, 23, 0},
+		drop: func(project *AtlantisProject) bool {
+			set := project.ExecutionOrderGroup != nil
+			project.ExecutionOrderGroup = nil
+			return set
+		},
+	},
+	{
+		field: "depends_on",
+		since: [3]int{0, 24, 0},
+		drop: func(project *AtlantisProject) bool {
+			set := len(project.DependsOn) > 0
+			project.DependsOn = nil
+			return set
+		},
+	},
+	{
+		field: "repo_locks",
+		since: [3]int{0, 27, 0},
+		drop: func(project *AtlantisProject) bool {
+			set := project.RepoLocks != nil
+			project.RepoLocks = nil
+			return set
+		},
+	},
+}
+
+// Parses an Atlantis version like 0.27.0 or v0.27.0. A missing minor or patch version is 0.
+func parseAtlantisVersion(version string) ([3]int, error) {
+	parsed := [3]int{}
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) > 3 {
+		return parsed, fmt.Errorf("invalid --atlantis-version %q, must be like 0.27.0", version)
+	}
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return parsed, fmt.Errorf("invalid --atlantis-version %q, must be like 0.27.0", version)
+		}
+		parsed[i] = number
+	}
+	return parsed, nil
+}
+
+// Checks that `--atlantis-version` is a version, if given
+func validateAtlantisVersion() error {
+	if atlantisVersion == "" {
+		return nil
+	}
+	_, err := parseAtlantisVersion(atlantisVersion)
+	return err
+}
+
+func versionBefore(version [3]int, other [3]int) bool {
+	for i := range version {
+		if version[i] != other[i] {
+			return version[i] < other[i]
+		}
+	}
+	return false
+}
+
+// Removes the project fields that the Atlantis of `--atlantis-version` does not support, warning once for every
+// dropped field. Without `--atlantis-version` all fields are kept.
+func dropUnsupportedFields(projects []AtlantisProject) {
+	if atlantisVersion == "" {
+		return
+	}
+	// Validated in main
+	target, _ := parseAtlantisVersion(atlantisVersion)
+
+	for _, feature := range atlantisFeatures {
+		if !versionBefore(target, feature.since) {
+			continue
+		}
+		dropped := 0
+		for i := range projects {
+			if feature.drop(&projects[i]) {
+				dropped++
+			}
+		}
+		if dropped > 0 {
+			diagnostics.Warnf(gitRoot, "dropped %s from %d projects, Atlantis %s does not support it before %d.%d.%d", feature.field, dropped, atlantisVersion, feature.since[0], feature.since[1], feature.since[2])
+		}
+	}
+}
@@ -1,7 +1,13 @@
 package cmd
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 
@@ -28,6 +34,15 @@ type AtlantisConfig struct {
 	// Workflows, which are not managed by this library other than
 	// the fact that this library preserves any existing workflows
 	Workflows interface{} `json:"workflows,omitempty"`
+
+	// Project keys that are allowed to be overridden
+	AllowedOverrides []string `json:"allowed_overrides,omitempty"`
+
+	// If projects are allowed to define their own workflows
+	AllowCustomWorkflows bool `json:"allow_custom_workflows,omitempty"`
+
+	// Keys without a field above, kept by ParseAtlantisConfig and written again by MarshalAtlantisConfig
+	unknownFields map[string]interface{}
 }
 
 // Represents an Atlantis Project directory
@@ -53,11 +68,42 @@ type AtlantisProject struct {
 	// We only want to output `apply_requirements` if explicitly stated in a local value
 	ApplyRequirements *[]string `json:"apply_requirements,omitempty"`
 
+	// Like `apply_requirements`, only output if set by `--plan-requirements` or a local value
+	PlanRequirements *[]string `json:"plan_requirements,omitempty"`
+
+	// When Atlantis locks the project, from `--repo-locks-mode` or the `atlantis_repo_locks_mode` local
+	RepoLocks *RepoLocksConfig `json:"repo_locks,omitempty"`
+
 	// Atlantis use ExecutionOrderGroup for sort projects before applying/planning
 	ExecutionOrderGroup *int `json:"execution_order_group,omitempty"`
 
 	// Atlantis uses DependsOn to define dependencies between projects
 	DependsOn []string `json:"depends_on,omitempty"`
+
+	// Tags to select projects by, from `--default-tags` and the `atlantis_tags` local
+	Tags []string `json:"tags,omitempty"`
+
+	// Inline workflow from `atlantis_workflow_steps`, registered under a generated name by registerCustomWorkflows
+	customWorkflow interface{}
+
+	// Description from `atlantis_description`, only written as a comment by addProjectDescriptions
+	description string
+
+	// Execution order group from `atlantis_execution_order_group`, used instead of the computed one
+	executionOrderGroup *int
+
+	// Isolation group from `atlantis_isolation_group`. Projects of different isolation groups never share an execution
+	// order group
+	isolationGroup string
+
+	// If the project is for a project hcl file, planning all modules below its dir together
+	hclProject bool
+
+	// If the project is kept from the existing config by `--preserve-projects` or `--update-only` instead of generated
+	preserved bool
+
+	// Keys without a field above, kept by ParseAtlantisConfig and written again by MarshalAtlantisConfig
+	unknownFields map[string]interface{}
 }
 
 // Autoplan settings for which plans affect other plans
@@ -69,22 +115,381 @@ type AutoplanConfig struct {
 	Enabled bool `json:"enabled"`
 }
 
-// Checks if an output file already exists. If it does, it reads it
+// The `--output` that writes the config to stdout instead of a file
+const stdoutOutputPath = "-"
+
+// The path of the existing config to preserve parts of, which is `--preserve-from` or else the `--output` file. There is
+// none when writing to stdout or only writing the projects without `--preserve-from`.
+func existingConfigPath() string {
+	if preserveFrom != "" {
+		return preserveFrom
+	}
+	if outputPath == stdoutOutputPath || outputProjectsOnly {
+		return ""
+	}
+	return outputPath
+}
+
+// Repo locking settings of a project
+type RepoLocksConfig struct {
+	// When the lock is taken: on_plan, on_apply or disabled
+	Mode string `json:"mode"`
+}
+
+// ParseAtlantisConfig parses the contents of an atlantis.yaml file. Keys of the config and of its projects that have no
+// field in AtlantisConfig or AtlantisProject are kept, so that MarshalAtlantisConfig writes them again.
+func ParseAtlantisConfig(bytes []byte) (*AtlantisConfig, error) {
+	config := AtlantisConfig{}
+	if err := yaml.Unmarshal(bytes, &config); err != nil {
+		return nil, err
+	}
+
+	raw := map[string]interface{}{}
+	if err := yaml.Unmarshal(bytes, &raw); err != nil {
+		return nil, err
+	}
+	config.unknownFields = unknownFields(raw, reflect.TypeOf(config))
+	if rawProjects, ok := raw["projects"].([]interface{}); ok {
+		for i, rawProject := range rawProjects {
+			if rawProject, ok := rawProject.(map[string]interface{}); ok && i < len(config.Projects) {
+				config.Projects[i].unknownFields = unknownFields(rawProject, reflect.TypeOf(AtlantisProject{}))
+			}
+		}
+	}
+
+	return &config, nil
+}
+
+// MarshalAtlantisConfig converts a config to YAML, including the unknown keys kept by ParseAtlantisConfig. Keys are
+// sorted, so the same config is always written the same way.
+func MarshalAtlantisConfig(config *AtlantisConfig) ([]byte, error) {
+	bytes, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	hasUnknownFields := len(config.unknownFields) > 0
+	for _, project := range config.Projects {
+		hasUnknownFields = hasUnknownFields || len(project.unknownFields) > 0
+	}
+	if !hasUnknownFields {
+		return bytes, nil
+	}
+
+	merged := map[string]interface{}{}
+	if err := yaml.Unmarshal(bytes, &merged); err != nil {
+		return nil, err
+	}
+	for key, value := range config.unknownFields {
+		merged[key] = value
+	}
+	if mergedProjects, ok := merged["projects"].([]interface{}); ok {
+		for i, mergedProject := range mergedProjects {
+			if mergedProject, ok := mergedProject.(map[string]interface{}); ok && i < len(config.Projects) {
+				for key, value := range config.Projects[i].unknownFields {
+					mergedProject[key] = value
+				}
+			}
+		}
+	}
+
+	return yaml.Marshal(merged)
+}
+
+// MarshalAtlantisProjects converts projects to a YAML list, like the `projects` of MarshalAtlantisConfig without the
+// rest of the config
+func MarshalAtlantisProjects(projects []AtlantisProject) ([]byte, error) {
+	bytes, err := MarshalAtlantisConfig(&AtlantisConfig{Projects: projects})
+	if err != nil {
+		return nil, err
+	}
+
+	config := map[string]interface{}{}
+	if err := yaml.Unmarshal(bytes, &config); err != nil {
+		return nil, err
+	}
+	marshalledProjects, ok := config["projects"].([]interface{})
+	if !ok {
+		marshalledProjects = []interface{}{}
+	}
+	return yaml.Marshal(marshalledProjects)
+}
+
+// Returns the keys of a parsed YAML object that are not the JSON name of any field of the struct type, or nil if
+// there are none
+func unknownFields(object map[string]interface{}, structType reflect.Type) map[string]interface{} {
+	known := map[string]bool{}
+	for i := 0; i < structType.NumField(); i++ {
+		if name := strings.Split(structType.Field(i).Tag.Get("json"), ",")[0]; name != "" {
+			known[name] = true
+		}
+	}
+
+	var unknown map[string]interface{}
+	for key, value := range object {
+		if known[key] {
+			continue
+		}
+		if unknown == nil {
+			unknown = map[string]interface{}{}
+		}
+		unknown[key] = value
+	}
+	return unknown
+}
+
+// Checks if an existing config file exists. If it does, it reads it
 // in to preserve some parts of the old config
 func readOldConfig() (*AtlantisConfig, error) {
 	// The old file not existing is not an error, as it should not exist on the very first run
-	bytes, err := os.ReadFile(outputPath)
+	bytes, err := os.ReadFile(existingConfigPath())
 	if err != nil {
 		log.Info("Could not find an old config file. Starting from scratch")
 		return nil, nil
 	}
 
 	// The old file being malformed is an actual error
-	config := AtlantisConfig{}
-	err = yaml.Unmarshal(bytes, &config)
+	return ParseAtlantisConfig(bytes)
+}
+
+// Top level keys of the config controlled by each of the names accepted by `--manage`
+var managedConfigKeys = map[string][]string{
+	"version":   {"version"},
+	"automerge": {"automerge"},
+	"parallel":  {"parallel_plan", "parallel_apply"},
+	"projects":  {"projects"},
+	"workflows": {"workflows"},
+
+	"allowed_overrides":      {"allowed_overrides"},
+	"allow_custom_workflows": {"allow_custom_workflows"},
+}
+
+// Checks that all names given to `--manage` are known
+func validateManage() error {
+	for _, name := range manage {
+		if _, ok := managedConfigKeys[name]; !ok {
+			known := []string{}
+			for knownName := range managedConfigKeys {
+				known = append(known, knownName)
+			}
+			sort.Strings(known)
+			return fmt.Errorf("unknown --manage value %q, must be one of %s", name, strings.Join(known, ", "))
+		}
+	}
+
+	return nil
+}
+
+// Orders accepted by `--sort-projects-by`
+var projectSortOrders = []string{"dir", "name", "execution-order"}
+
+// Checks that `--sort-projects-by` names a known order, and that the execution order groups it needs are computed
+func validateSortProjectsBy() error {
+	known := false
+	for _, order := range projectSortOrders {
+		known = known || order == sortProjectsBy
+	}
+	if !known {
+		return fmt.Errorf("unknown --sort-projects-by value %q, must be one of %s", sortProjectsBy, strings.Join(projectSortOrders, ", "))
+	}
+	if sortProjectsBy == "execution-order" && !executionOrderGroups {
+		return fmt.Errorf("--sort-projects-by=execution-order requires --execution-order-groups")
+	}
+
+	return nil
+}
+
+// Modes accepted by `--repo-locks-mode` and the `atlantis_repo_locks_mode` local
+var repoLocksModes = []string{"on_plan", "on_apply", "disabled"}
+
+// Checks that a repo locks mode set by source, a flag or local, is known to Atlantis
+func validateRepoLocksMode(mode string, source string) error {
+	for _, known := range repoLocksModes {
+		if mode == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown %s value %q, must be one of %s", source, mode, strings.Join(repoLocksModes, ", "))
+}
+
+// Sorts the projects in the order given by `--sort-projects-by`. Ties are broken by dir so the output is stable.
+func sortProjects(projects []AtlantisProject, by string) {
+	sort.SliceStable(projects, func(i, j int) bool {
+		switch by {
+		case "name":
+			if projects[i].Name != projects[j].Name {
+				return projects[i].Name < projects[j].Name
+			}
+		case "execution-order":
+			if *projects[i].ExecutionOrderGroup != *projects[j].ExecutionOrderGroup {
+				return *projects[i].ExecutionOrderGroup < *projects[j].ExecutionOrderGroup
+			}
+		}
+		return projects[i].Dir < projects[j].Dir
+	})
+}
+
+// Converts the config to YAML. When `--manage` is set and an existing config file exists, only the top level keys it
+// names are taken from the generated config. All other keys of the existing file are kept untouched, including keys
+// like `allowed_overrides` that this tool doesn't know about.
+func marshalConfig(config *AtlantisConfig) ([]byte, error) {
+	if len(manage) == 0 {
+		return MarshalAtlantisConfig(config)
+	}
+
+	bytes, err := os.ReadFile(existingConfigPath())
 	if err != nil {
+		return MarshalAtlantisConfig(config)
+	}
+
+	existing := map[string]interface{}{}
+	if err := yaml.Unmarshal(bytes, &existing); err != nil {
 		return nil, err
 	}
 
-	return &config, nil
+	generatedBytes, err := MarshalAtlantisConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	generated := map[string]interface{}{}
+	if err := yaml.Unmarshal(generatedBytes, &generated); err != nil {
+		return nil, err
+	}
+
+	for _, name := range manage {
+		for _, key := range managedConfigKeys[name] {
+			if value, ok := generated[key]; ok {
+				existing[key] = value
+			} else {
+				delete(existing, key)
+			}
+		}
+	}
+
+	return yaml.Marshal(existing)
+}
+
+// Registers the `atlantis_workflow_steps` of each project as a workflow named after the project, or its dir for
+// projects without a name, and points the project at it. Projects must already be sorted, so names that still collide
+// get the same numbered suffix on every run. Names of existing workflows are taken too, unless they hold the same
+// definition, like the workflow registered for the project on a previous run. With `--dedupe-workflows`, projects
+// with identical definitions share the workflow of the first of them.
+func registerCustomWorkflows(config *AtlantisConfig) error {
+	workflows := map[string]interface{}{}
+	if config.Workflows != nil {
+		existingWorkflows, ok := config.Workflows.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("existing workflows must be a map to add workflows from atlantis_workflow_steps")
+		}
+		workflows = existingWorkflows
+	}
+	taken := func(name string, definition string) bool {
+		existing, ok := workflows[decorateWorkflowName(name)]
+		if !ok {
+			return false
+		}
+		existingJSON, err := json.Marshal(existing)
+		return err != nil || string(existingJSON) != definition
+	}
+
+	regex := regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+	registered := map[string]bool{}
+	// With `--dedupe-workflows`, the name of the workflow registered for each distinct definition
+	registeredDefinitions := map[string]string{}
+	for i := range config.Projects {
+		project := &config.Projects[i]
+		if project.customWorkflow == nil {
+			continue
+		}
+
+		// Maps are marshalled with sorted keys, so identical definitions give identical JSON
+		definitionJSON, err := json.Marshal(project.customWorkflow)
+		if err != nil {
+			return err
+		}
+		definition := string(definitionJSON)
+		if dedupeWorkflows {
+			if name, ok := registeredDefinitions[definition]; ok {
+				project.Workflow = decorateWorkflowName(name)
+				continue
+			}
+		}
+
+		baseName := project.Name
+		if baseName == "" {
+			baseName = project.Dir
+		}
+		baseName = regex.ReplaceAllString(baseName, "_") + "_custom_workflow"
+		name := baseName
+		for suffix := 2; registered[name] || taken(name, definition); suffix++ {
+			name = fmt.Sprintf("%s_%d", baseName, suffix)
+		}
+		registered[name] = true
+		if dedupeWorkflows {
+			registeredDefinitions[definition] = name
+		}
+
+		workflows[decorateWorkflowName(name)] = project.customWorkflow
+		project.Workflow = decorateWorkflowName(name)
+	}
+
+	if len(registered) > 0 {
+		config.Workflows = workflows
+	}
+	return nil
+}
+
+// Writes the description of each project as a comment above its entry. The YAML library has no support for comments,
+// so they are added to the marshalled output, finding each project by its `dir`.
+func addProjectDescriptions(yamlBytes []byte, projects []AtlantisProject) []byte {
+	descriptions := map[string]string{}
+	for _, project := range projects {
+		if project.description != "" {
+			descriptions[project.Dir] = project.description
+		}
+	}
+
+	output := []string{}
+	project := []string{}
+	flushProject := func() {
+		for _, line := range project {
+			if !strings.HasPrefix(line, "  dir: ") {
+				continue
+			}
+			// Quoted dirs like '.' are unquoted by unmarshalling the value on its own
+			var dir string
+			if err := yaml.Unmarshal([]byte(strings.TrimPrefix(line, "  dir: ")), &dir); err != nil {
+				break
+			}
+			if description, ok := descriptions[dir]; ok {
+				for _, descriptionLine := range strings.Split(strings.TrimRight(description, "\n"), "\n") {
+					output = append(output, strings.TrimRight("# "+descriptionLine, " "))
+				}
+			}
+			break
+		}
+		output = append(output, project...)
+		project = []string{}
+	}
+
+	// Output of `--output-projects-only` is a list of projects without a projects key
+	inProjects := strings.HasPrefix(string(yamlBytes), "- ")
+	for _, line := range strings.Split(string(yamlBytes), "\n") {
+		isTopLevelKey := line != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "- ")
+		if isTopLevelKey || (inProjects && strings.HasPrefix(line, "- ")) {
+			flushProject()
+		}
+		if isTopLevelKey {
+			inProjects = line == "projects:"
+		}
+
+		if inProjects && (strings.HasPrefix(line, "- ") || len(project) > 0) {
+			project = append(project, line)
+		} else {
+			output = append(output, line)
+		}
+	}
+	flushProject()
+
+	return []byte(strings.Join(output, "\n"))
 }
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Severity of a Diagnostic
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Diagnostic is a problem found while generating the config that did not stop the generation
+type Diagnostic struct {
+	Severity Severity
+	// The config file the problem was found in
+	File    string
+	Message string
+}
+
+func (diagnostic Diagnostic) String() string {
+	return fmt.Sprintf("%s: %s: %s", diagnostic.Severity, diagnostic.File, diagnostic.Message)
+}
+
+// Diagnostics collects the problems found during a run, so they can be summarized at the end or checked by callers
+// instead of only being scattered through the logs. Projects are created concurrently, so it is safe for concurrent use.
+type Diagnostics struct {
+	mtx   sync.Mutex
+	items []Diagnostic
+}
+
+// Logs and records a warning about file
+func (diagnostics *Diagnostics) Warnf(file string, format string, args ...interface{}) {
+	diagnostics.add(Diagnostic{Severity: SeverityWarning, File: file, Message: fmt.Sprintf(format, args...)})
+}
+
+// Logs and records an error about file that was recovered from
+func (diagnostics *Diagnostics) Errorf(file string, format string, args ...interface{}) {
+	diagnostics.add(Diagnostic{Severity: SeverityError, File: file, Message: fmt.Sprintf(format, args...)})
+}
+
+func (diagnostics *Diagnostics) add(diagnostic Diagnostic) {
+	if diagnostic.Severity == SeverityError {
+		log.Errorf("%s: %s", diagnostic.File, diagnostic.Message)
+	} else {
+		log.Warnf("%s: %s", diagnostic.File, diagnostic.Message)
+	}
+
+	diagnostics.mtx.Lock()
+	defer diagnostics.mtx.Unlock()
+	diagnostics.items = append(diagnostics.items, diagnostic)
+}
+
+// All returns the collected diagnostics, sorted by file so concurrent runs give the same order
+func (diagnostics *Diagnostics) All() []Diagnostic {
+	diagnostics.mtx.Lock()
+	defer diagnostics.mtx.Unlock()
+
+	all := append([]Diagnostic{}, diagnostics.items...)
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].File != all[j].File {
+			return all[i].File < all[j].File
+		}
+		return all[i].Message < all[j].Message
+	})
+	return all
+}
+
+// Logs all collected diagnostics again at the end of a run, as the original log lines are interleaved with the output
+// of concurrently created projects
+func (diagnostics *Diagnostics) LogSummary() {
+	all := diagnostics.All()
+	if len(all) == 0 {
+		return
+	}
+
+	log.Warnf("Generation finished with %d diagnostic(s):", len(all))
+	for _, diagnostic := range all {
+		log.Warn(diagnostic.String())
+	}
+}
+
+// Diagnostics of the current run, returned by RunWithFlagsAndDiagnostics
+var diagnostics = &Diagnostics{}
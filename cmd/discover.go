@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/util"
+)
+
+// Behavior profile of a range of Terragrunt releases, selected with `--terragrunt-compat`
+type terragruntCompatProfile struct {
+	// Root configurations that are included by child modules rather than being modules themselves
+	rootConfigFiles []string
+}
+
+var terragruntCompatProfiles = map[string]terragruntCompatProfile{
+	// Terragrunt before root.hcl, where children include a parent terragrunt.hcl that is discovered like any module
+	"legacy": {rootConfigFiles: []string{}},
+	// Terragrunt since root.hcl became the parent config children include by default
+	"modern": {rootConfigFiles: []string{"root.hcl", "root.hcl.json"}},
+}
+
+// Names of the profiles accepted by `--terragrunt-compat`
+var terragruntCompatProfileNames = []string{"legacy", "modern"}
+
+// Checks that `--terragrunt-compat` names a known profile
+func validateTerragruntCompat() error {
+	if _, ok := terragruntCompatProfiles[terragruntCompat]; !ok {
+		return fmt.Errorf("unknown --terragrunt-compat value %q, must be one of %s", terragruntCompat, strings.Join(terragruntCompatProfileNames, ", "))
+	}
+
+	return nil
+}
+
+// Root configurations of the `--terragrunt-compat` profile
+func rootConfigFiles() []string {
+	return terragruntCompatProfiles[terragruntCompat].rootConfigFiles
+}
+
+// discoveredFiles holds every file of interest below a root directory, found in a single traversal so large repos
+// are only read from disk once
+type discoveredFiles struct {
+	// Absolute path of the directory that was walked
+	root string
+
+	// Absolute paths of root configs like root.hcl followed by all Terragrunt module configs, in walk order
+	configFiles []string
+
+	// Absolute paths of the directories containing each of the `--project-hcl-files`, by file name
+	projectHclDirs map[string][]string
+}
+
+// Walks rootPath once, collecting Terragrunt configs the same way config.FindConfigFilesInPath does alongside any
+// root configs and project hcl files
+func discoverFiles(rootPath string) (*discoveredFiles, error) {
+	absoluteRoot, err := filepath.Abs(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := options.NewTerragruntOptionsWithConfigPath(absoluteRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	isProjectHclFile := map[string]bool{}
+	for _, projectHclFile := range projectHclFiles {
+		isProjectHclFile[projectHclFile] = true
+	}
+
+	discovered := &discoveredFiles{
+		root:           absoluteRoot,
+		projectHclDirs: map[string][]string{},
+	}
+	moduleConfigFiles := []string{}
+	err = walkDir(absoluteRoot, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if !entry.IsDir() {
+			dir := filepath.Dir(path)
+			if isProjectHclFile[entry.Name()] && !isSkippedDir(absoluteRoot, dir, opts) {
+				discovered.projectHclDirs[entry.Name()] = append(discovered.projectHclDirs[entry.Name()], dir)
+			}
+			return nil
+		}
+
+		if isSkippedDir(absoluteRoot, path, opts) {
+			return filepath.SkipDir
+		}
+
+		if configFile := firstExistingFile(path, rootConfigFiles()); configFile != "" {
+			discovered.configFiles = append(discovered.configFiles, configFile)
+		}
+		if configFile := firstExistingFile(path, config.DefaultTerragruntConfigPaths); configFile != "" {
+			moduleConfigFiles = append(moduleConfigFiles, configFile)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	discovered.configFiles = append(discovered.configFiles, moduleConfigFiles...)
+	return discovered, nil
+}
+
+// Returns the config files at or below dir. Directories outside of the walked root are discovered separately.
+func (discovered *discoveredFiles) configFilesIn(dir string) ([]string, error) {
+	absoluteDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	relativeDir, err := filepath.Rel(discovered.root, absoluteDir)
+	if err != nil || relativeDir == ".." || strings.HasPrefix(relativeDir, ".."+string(filepath.Separator)) {
+		outside, err := discoverFiles(absoluteDir)
+		if err != nil {
+			return nil, err
+		}
+		return outside.configFiles, nil
+	}
+
+	configFiles := []string{}
+	for _, configFile := range discovered.configFiles {
+		if util.HasPathPrefix(configFile, absoluteDir) {
+			configFiles = append(configFiles, configFile)
+		}
+	}
+	return configFiles, nil
+}
+
+// Checks if nothing should be discovered in dir, as it is ignored by Terragrunt or inside of a git submodule. Dirs only
+// reachable through a symlink cycle are left out by walkDir.
+func isSkippedDir(rootPath string, dir string, opts *options.TerragruntOptions) bool {
+	return isIgnoredModuleDir(dir, opts) || (!includeSubmodules && isInSubmodule(rootPath, dir))
+}
+
+// Checks if dir is inside of a git submodule below rootPath. Submodules have a `.git` file pointing at their git dir,
+// while the repo itself has a `.git` directory. Their contents are usually vendored code that is not managed here.
+func isInSubmodule(rootPath string, dir string) bool {
+	root := filepath.Clean(rootPath)
+	for current := filepath.Clean(dir); strings.HasPrefix(current, root+string(filepath.Separator)); current = filepath.Dir(current) {
+		if info, err := os.Lstat(filepath.Join(current, ".git")); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks for the directories Terragrunt skips when discovering modules: its cache, the Terraform data dir and the
+// download dir
+func isIgnoredModuleDir(path string, opts *options.TerragruntOptions) bool {
+	if util.ContainsPath(path, util.TerragruntCacheDir) {
+		return true
+	}
+
+	dataDir := opts.TerraformDataDir()
+	if filepath.IsAbs(dataDir) {
+		if util.HasPathPrefix(path, dataDir) {
+			return true
+		}
+	} else if util.ContainsPath(path, dataDir) {
+		return true
+	}
+
+	return strings.Contains(path, opts.DownloadDir)
+}
+
+// Returns the path of the first of the given file names that exists in dir, or an empty string if none do
+func firstExistingFile(dir string, names []string) string {
+	for _, name := range names {
+		path := util.JoinPath(dir, name)
+		if !util.IsDir(path) && util.FileExists(path) {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// Walks the tree with filepath.WalkDir, which avoids a stat per entry. Terragrunt's symlink aware walker only comes
+// with the filepath.Walk signature, so it is adapted when `--follow-symlinks` is set.
+//
+// That walker passes symlinked dirs to walkFn as dirs, but to filepath.Walk they are files, where SkipDir would also
+// skip their siblings. So the adapter remembers the dirs walkFn skips and leaves out everything below them itself. It
+// also skips symlinks pointing back to a dir they are in, which Terragrunt stops only after walking them once, so
+// without this every module below the loop would be discovered a second time.
+func walkDir(rootPath string, walkFn fs.WalkDirFunc) error {
+	if !followSymlinks {
+		return filepath.WalkDir(rootPath, walkFn)
+	}
+
+	skippedDirs := map[string]bool{}
+	// The real dir of every walked dir, by the path it was walked as
+	realDirs := map[string]string{}
+	return util.WalkWithSymlinks(rootPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info == nil {
+			return walkFn(path, nil, err)
+		}
+
+		linkInfo, err := os.Lstat(path)
+		isSymlink := err == nil && linkInfo.Mode()&os.ModeSymlink != 0
+		// filepath.Walk only prunes with SkipDir at dirs that are not symlinks
+		skip := error(nil)
+		if info.IsDir() && !isSymlink {
+			skip = filepath.SkipDir
+		}
+
+		if skippedDirs[filepath.Dir(path)] {
+			if info.IsDir() {
+				skippedDirs[path] = true
+			}
+			return skip
+		}
+
+		if info.IsDir() {
+			realDir, ok := "", false
+			if parentRealDir, parentWalked := realDirs[filepath.Dir(path)]; parentWalked && !isSymlink {
+				realDir, ok = filepath.Join(parentRealDir, filepath.Base(path)), true
+			} else if evaluatedDir, err := filepath.EvalSymlinks(path); err == nil {
+				realDir, ok = evaluatedDir, true
+			}
+			if ok && isSymlink && isWalkedAbove(realDirs, rootPath, path, realDir) {
+				skippedDirs[path] = true
+				return nil
+			}
+			if ok {
+				realDirs[path] = realDir
+			}
+		}
+
+		if err := walkFn(path, fs.FileInfoToDirEntry(info), nil); err != filepath.SkipDir {
+			return err
+		}
+		if info.IsDir() {
+			skippedDirs[path] = true
+		}
+		return skip
+	})
+}
+
+// Checks if realDir was already walked as one of the dirs above path, which happens when a symlink points back to one
+// of its own parents
+func isWalkedAbove(realDirs map[string]string, rootPath string, path string, realDir string) bool {
+	for dir := filepath.Dir(path); ; dir = filepath.Dir(dir) {
+		if realDirs[dir] == realDir {
+			return true
+		}
+		if dir == filepath.Clean(rootPath) || dir == filepath.Dir(dir) {
+			return false
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// Prefix of the environment variables setting the flags of generate, like `TGAC_AUTOPLAN` for `--autoplan`
+const envVarPrefix = "TGAC_"
+
+// Returns the environment variable of a flag: its name upper cased, with dashes replaced by underscores
+func envVarName(flagName string) string {
+	return envVarPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// Sets every flag that was not given on the command line from its environment variable, so flags take precedence over
+// the environment, which takes precedence over the defaults. The environment variables of the aliases of a flag set it
+// too, like the alias flags do.
+func applyEnvVars(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Changed {
+			return
+		}
+		names := []string{flag.Name}
+		for alias, flagName := range flagAliases {
+			if flagName == flag.Name {
+				names = append(names, alias)
+			}
+		}
+		sort.Strings(names[1:])
+
+		for _, name := range names {
+			value, ok := os.LookupEnv(envVarName(name))
+			if !ok {
+				continue
+			}
+			if setErr := flags.Set(flag.Name, value); setErr != nil {
+				err = fmt.Errorf("invalid %s: %w", envVarName(name), setErr)
+				return
+			}
+		}
+	})
+	return err
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// Reads the patterns of `--filter-file`, one per line. Blank lines and lines starting with `#` are skipped, so the file
+// can be written by another step of a pipeline, like a list of the directories changed in a pull request.
+func readFilterFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	patterns := []string{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return patterns, nil
+}
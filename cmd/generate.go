@@ -1,28 +1,32 @@
 package cmd
 
 import (
-	"github.com/gruntwork-io/terragrunt/util"
 	"regexp"
 	"sort"
 
-	"github.com/hashicorp/go-getter"
+	"github.com/bmatcuk/doublestar"
 	log "github.com/sirupsen/logrus"
 
-	"github.com/ghodss/yaml"
 	"github.com/gruntwork-io/terragrunt/config"
 	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/util"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
 	"golang.org/x/sync/singleflight"
 
 	"context"
+	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Parse env vars into a map
@@ -49,6 +53,16 @@ func makePathAbsolute(path string, parentPath string) string {
 	return filepath.Join(parentDir, path)
 }
 
+// Checks if a file is larger than `--max-file-size`. Files that can't be read are left for the parser to report.
+func exceedsMaxFileSize(path string) bool {
+	if maxFileSize <= 0 {
+		return false
+	}
+
+	info, err := os.Stat(path)
+	return err == nil && info.Size() > maxFileSize
+}
+
 var requestGroup singleflight.Group
 
 // Set up a cache for the getDependencies function
@@ -81,6 +95,38 @@ func (m *GetDependenciesCache) get(k string) (getDependenciesOutput, bool) {
 
 var getDependenciesCache = newGetDependenciesCache()
 
+// The flags that the results of getDependencies and the module sources cache depend on. Computations are keyed with
+// them as well, so configs generated with different flags in one process never share results.
+func dependencyOptionsFingerprint() string {
+	return fmt.Sprintf(
+		"cascade-dependencies=%t,ignore-dependency-blocks=%t,ignore-parent-terragrunt=%t,include-root-project=%t,track-read-files=%t,relativize-extra-dependencies=%t,resolve-remote-local-submodules=%t,offline=%t,ignore-tf-parse-errors=%t,max-file-size=%d",
+		cascadeDependencies,
+		ignoreDependencyBlocks,
+		ignoreParentTerragrunt,
+		includeRootProject,
+		trackReadFiles,
+		relativizeExtraDependencies,
+		resolveRemoteLocalSubmodules,
+		offline,
+		ignoreTfParseErrors,
+		maxFileSize,
+	)
+}
+
+// If the config at path is the terragrunt config at the top of a `--root`, which `--include-root-project` gives a
+// project when it has a terraform source, even though the modules below include it
+func isRootProjectConfig(path string) bool {
+	if !includeRootProject || util.ListContainsElement(rootConfigFiles(), filepath.Base(path)) {
+		return false
+	}
+	return util.ListContainsElement(rootDirs, filepath.Dir(filepath.Clean(path))+string(filepath.Separator))
+}
+
+// The key of the dependencies of the config at path, in getDependenciesCache and requestGroup
+func dependenciesCacheKey(path string) string {
+	return path + "@" + dependencyOptionsFingerprint()
+}
+
 func uniqueStrings(str []string) []string {
 	keys := make(map[string]bool)
 	list := []string{}
@@ -93,6 +139,392 @@ func uniqueStrings(str []string) []string {
 	return list
 }
 
+// Prefix of when_modified entries excluding the files they match, like `!../shared/generated/**`
+const negatedDependencyPrefix = "!"
+
+// Splits the `!` of a negated when_modified entry from its path, so the path can be made relative like any other
+func splitNegation(dependency string) (string, string) {
+	if strings.HasPrefix(dependency, negatedDependencyPrefix) {
+		return negatedDependencyPrefix, strings.TrimPrefix(dependency, negatedDependencyPrefix)
+	}
+	return "", dependency
+}
+
+// Moves the negated when_modified entries after all others. Atlantis applies the entries in order, so a negated entry
+// only excludes files matched by the entries before it.
+func negationsLast(whenModified []string) []string {
+	entries, negations := []string{}, []string{}
+	for _, entry := range whenModified {
+		if strings.HasPrefix(entry, negatedDependencyPrefix) {
+			negations = append(negations, entry)
+		} else {
+			entries = append(entries, entry)
+		}
+	}
+	return append(entries, negations...)
+}
+
+// Moves the project dir of a module the given number of levels up towards the repo root, rewriting the module relative
+// when_modified paths so they still match the same files. Levels above the repo root stop at the root.
+func liftProjectDir(moduleDir string, whenModified []string, levels int) (string, []string) {
+	dir := moduleDir
+	for i := 0; i < levels && dir != "."; i++ {
+		dir = path.Dir(dir)
+	}
+	if dir == moduleDir {
+		return dir, whenModified
+	}
+
+	relativeModuleDir := moduleDir
+	if dir != "." {
+		relativeModuleDir = strings.TrimPrefix(moduleDir, dir+"/")
+	}
+
+	lifted := []string{}
+	for _, entry := range whenModified {
+		negation, entryPath := splitNegation(entry)
+		lifted = append(lifted, negation+path.Join(relativeModuleDir, entryPath))
+	}
+	return dir, uniqueStrings(lifted)
+}
+
+// The number of directory levels the project of the module in moduleDir is lifted by. `--group-by-depth` lifts every
+// module below that depth to its ancestor at the depth, `--include-parent-in-project-dir` lifts all modules alike.
+func projectDirLiftLevels(moduleDir string) int {
+	if groupByDepth == 0 {
+		return projectDirLevels
+	}
+	if moduleDir == "." {
+		return 0
+	}
+	return max(len(strings.Split(moduleDir, "/"))-groupByDepth, 0)
+}
+
+// Merges a module project into the project of another module lifted into the same dir by
+// `--include-parent-in-project-dir` or `--group-by-depth`. The merged when_modified are sorted, as the modules are
+// created concurrently. Negated entries exclude files matched by all entries before them, so modules that have any
+// would also exclude files of the other modules and are not merged.
+func mergeLiftedProject(existing *AtlantisProject, project AtlantisProject) error {
+	existingSettings, projectSettings := *existing, project
+	existingSettings.Autoplan.WhenModified, projectSettings.Autoplan.WhenModified = nil, nil
+	if !reflect.DeepEqual(existingSettings, projectSettings) {
+		return fmt.Errorf("modules lifted into the project dir %s by --include-parent-in-project-dir or --group-by-depth have different settings, like their workflow or terraform version", project.Dir)
+	}
+	if hasNegations(existing.Autoplan.WhenModified) || hasNegations(project.Autoplan.WhenModified) {
+		return fmt.Errorf("modules lifted into the project dir %s by --include-parent-in-project-dir or --group-by-depth have negated when_modified entries, which would also exclude the files of the other modules", project.Dir)
+	}
+
+	whenModified := uniqueStrings(append(existing.Autoplan.WhenModified, project.Autoplan.WhenModified...))
+	sort.Strings(whenModified)
+	existing.Autoplan.WhenModified = whenModified
+	return nil
+}
+
+// Checks if any when_modified entry is negated
+func hasNegations(whenModified []string) bool {
+	for _, entry := range whenModified {
+		if negation, _ := splitNegation(entry); negation != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Namespaces a workflow name with `--workflow-prefix` and `--workflow-suffix`. Projects without a workflow keep none.
+func decorateWorkflowName(name string) string {
+	if name == "" {
+		return ""
+	}
+	return workflowPrefix + name + workflowSuffix
+}
+
+// Returns the `--default-tags` followed by the `atlantis_tags` of a project, or nil if there are none
+func projectTags(locals ResolvedLocals) []string {
+	tags := uniqueStrings(append(append([]string{}, defaultTags...), locals.Tags...))
+	if len(tags) == 0 {
+		return nil
+	}
+	return tags
+}
+
+// Resolves the apply requirements of a project. `--force-apply-requirements` wins over everything, to enforce a policy.
+// Otherwise the `atlantis_apply_requirements` local replaces the `--apply-requirements` default, or is added to it with
+// `--merge-apply-requirements`. Projects of project hcl files resolve them the same way as modules.
+func resolveApplyRequirements(locals ResolvedLocals) *[]string {
+	if len(forceApplyRequirements) > 0 {
+		return canonicalApplyRequirements(&forceApplyRequirements)
+	}
+
+	var requirements *[]string
+	if len(defaultApplyRequirements) > 0 {
+		requirements = &defaultApplyRequirements
+	}
+	if locals.ApplyRequirements != nil {
+		if mergeApplyRequirements {
+			merged := append(append([]string{}, defaultApplyRequirements...), locals.ApplyRequirements...)
+			requirements = &merged
+		} else {
+			requirements = &locals.ApplyRequirements
+		}
+	}
+
+	return canonicalApplyRequirements(requirements)
+}
+
+// Resolves the plan requirements of a project. The `atlantis_plan_requirements` local replaces the
+// `--plan-requirements` default.
+func resolvePlanRequirements(locals ResolvedLocals) *[]string {
+	if locals.PlanRequirements != nil {
+		return canonicalApplyRequirements(&locals.PlanRequirements)
+	}
+	if len(defaultPlanRequirements) > 0 {
+		return canonicalApplyRequirements(&defaultPlanRequirements)
+	}
+
+	return nil
+}
+
+// Resolves the `repo_locks` of a project from the `atlantis_repo_locks_mode` local or `--repo-locks-mode`. Returns nil
+// when neither is set, leaving the Atlantis default.
+func resolveRepoLocks(locals ResolvedLocals) (*RepoLocksConfig, error) {
+	if locals.RepoLocksMode == "" {
+		if repoLocksMode == "" {
+			return nil, nil
+		}
+		return &RepoLocksConfig{Mode: repoLocksMode}, nil
+	}
+
+	if err := validateRepoLocksMode(locals.RepoLocksMode, "atlantis_repo_locks_mode"); err != nil {
+		return nil, err
+	}
+	return &RepoLocksConfig{Mode: locals.RepoLocksMode}, nil
+}
+
+// Sorts and dedupes apply or plan requirements, so the same requirements give the same output in whichever order the
+// flag or locals list them. nil stays nil as it leaves the requirements out, and an explicitly empty list stays empty.
+func canonicalApplyRequirements(requirements *[]string) *[]string {
+	if requirements == nil {
+		return nil
+	}
+
+	canonical := uniqueStrings(*requirements)
+	sort.Strings(canonical)
+	return &canonical
+}
+
+// Finds the project that changes to files in depDir plan. That is the project with exactly this dir or else, as all
+// modules below a project hcl file are planned together, the project of the closest project hcl file above it. This
+// orders project hcl projects depending on modules of each other.
+func dependencyProject(projectsMap map[string]*AtlantisProject, depDir string) (*AtlantisProject, bool) {
+	if project, ok := projectsMap[depDir]; ok {
+		return project, true
+	}
+
+	for dir := depDir; dir != "." && dir != "/"; {
+		dir = path.Dir(dir)
+		if project, ok := projectsMap[dir]; ok && project.hclProject {
+			return project, true
+		}
+	}
+	return nil, false
+}
+
+// Projects of different `atlantis_isolation_group`s must never be planned or applied in parallel, so every computed
+// execution order group holding several isolation groups is split into consecutive groups, one per isolation group,
+// `--execution-order-step` apart. Projects without an isolation group stay in the first of them. The computed groups
+// after a split are shifted to make room, so dependencies still come first. Groups pinned with
+// `atlantis_execution_order_group` are kept, with a warning if that leaves isolation groups sharing one.
+func separateIsolationGroups(projects []AtlantisProject) {
+	isolationGroups := map[int][]string{}
+	for _, project := range projects {
+		if project.ExecutionOrderGroup == nil || project.executionOrderGroup != nil {
+			continue
+		}
+		group := *project.ExecutionOrderGroup
+		isolationGroups[group] = append(isolationGroups[group], project.isolationGroup)
+	}
+
+	orderGroups := []int{}
+	for group := range isolationGroups {
+		orderGroups = append(orderGroups, group)
+	}
+	sort.Ints(orderGroups)
+
+	// the new execution order group of each isolation group, by old execution order group
+	renumbered := map[int]map[string]int{}
+	shift := 0
+	for _, group := range orderGroups {
+		names := []string{}
+		for _, name := range uniqueStrings(isolationGroups[group]) {
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		renumbered[group] = map[string]int{"": group + shift}
+		for i, name := range names {
+			renumbered[group][name] = group + shift + i*executionOrderStep
+		}
+		shift += max(len(names)-1, 0) * executionOrderStep
+	}
+
+	for i := range projects {
+		if projects[i].ExecutionOrderGroup == nil || projects[i].executionOrderGroup != nil {
+			continue
+		}
+		group := renumbered[*projects[i].ExecutionOrderGroup][projects[i].isolationGroup]
+		projects[i].ExecutionOrderGroup = &group
+	}
+
+	sharedGroups := map[int][]string{}
+	pinnedGroups := map[int]bool{}
+	for _, project := range projects {
+		if project.ExecutionOrderGroup == nil || project.isolationGroup == "" {
+			continue
+		}
+		group := *project.ExecutionOrderGroup
+		sharedGroups[group] = uniqueStrings(append(sharedGroups[group], project.isolationGroup))
+		if project.executionOrderGroup != nil {
+			pinnedGroups[group] = true
+		}
+	}
+	warnedGroups := []int{}
+	for group := range pinnedGroups {
+		warnedGroups = append(warnedGroups, group)
+	}
+	sort.Ints(warnedGroups)
+	for _, group := range warnedGroups {
+		if names := sharedGroups[group]; len(names) > 1 {
+			sort.Strings(names)
+			diagnostics.Warnf(gitRoot, "isolation groups %s share execution_order_group %d, which is pinned by atlantis_execution_order_group", strings.Join(names, ", "), group)
+		}
+	}
+}
+
+// Replaces the existing project a generated project stands for, returning whether there was one. That is the project
+// with the same dir or, with `--merge-existing-projects-by-name`, first the kept project with the same name, so
+// projects keep their settings when their dir is renamed. Another kept project left at the new dir is dropped then, as
+// it would plan the same dir. When both the name and the dir changed nothing matches, and the old project is kept next
+// to the new one, to be found by `--check-orphans`.
+func replaceExistingProject(projects []AtlantisProject, project AtlantisProject) ([]AtlantisProject, bool) {
+	if mergeExistingProjectsByName && project.Name != "" {
+		for i := range projects {
+			if !projects[i].preserved || projects[i].Name != project.Name {
+				continue
+			}
+			if projects[i].Dir != project.Dir {
+				log.Infof("Moved project %s from %s to %s", project.Name, projects[i].Dir, project.Dir)
+			}
+			projects[i] = project
+
+			merged := []AtlantisProject{}
+			for j := range projects {
+				if j != i && projects[j].preserved && projects[j].Dir == project.Dir {
+					diagnostics.Warnf(filepath.Join(gitRoot, project.Dir), "dropping existing project %s, as project %s moved to its dir", projects[j].Name, project.Name)
+					continue
+				}
+				merged = append(merged, projects[j])
+			}
+			return merged, true
+		}
+	}
+
+	for i := range projects {
+		if projects[i].Dir == project.Dir {
+			projects[i] = project
+			return projects, true
+		}
+	}
+	return projects, false
+}
+
+// Makes the `--update-only` paths absolute. They must be inside of gitRoot, as project dirs are relative to it
+func resolveUpdateOnlyDirs() ([]string, error) {
+	dirs := []string{}
+	for _, updateOnlyPath := range updateOnly {
+		absolutePath, err := filepath.Abs(updateOnlyPath)
+		if err != nil {
+			return nil, err
+		}
+		if !util.HasPathPrefix(absolutePath+string(filepath.Separator), gitRoot) {
+			return nil, fmt.Errorf("--update-only %s is not inside of the root %s", updateOnlyPath, filepath.Clean(gitRoot))
+		}
+		dirs = append(dirs, absolutePath)
+	}
+	return dirs, nil
+}
+
+// Checks if the project of the given absolute dir is generated. With `--update-only`, only the projects below its
+// dirs are, all others are kept from the existing config.
+func isUpdatedDir(absoluteDir string) bool {
+	if len(updateOnlyDirs) == 0 {
+		return true
+	}
+	absoluteDir = filepath.Clean(absoluteDir)
+	for _, updateOnlyDir := range updateOnlyDirs {
+		if absoluteDir == updateOnlyDir || strings.HasPrefix(absoluteDir, strings.TrimSuffix(updateOnlyDir, string(filepath.Separator))+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks the projects kept from the existing config for orphans, whose dir has neither a terragrunt module nor a
+// project hcl file anymore. Atlantis fails to plan those, so they are warned about, or dropped with `--prune-orphans`.
+func checkOrphanProjects(projects []AtlantisProject) []AtlantisProject {
+	kept := []AtlantisProject{}
+	for _, project := range projects {
+		if !project.preserved || !isOrphanDir(filepath.Join(gitRoot, project.Dir)) {
+			kept = append(kept, project)
+			continue
+		}
+
+		if pruneOrphans {
+			log.Infof("Pruned project for %s, as there is no terragrunt module in its dir anymore", project.Dir)
+			continue
+		}
+		diagnostics.Warnf(existingConfigPath(), "project %s has no terragrunt module or project hcl file in its dir anymore", project.Dir)
+		kept = append(kept, project)
+	}
+	return kept
+}
+
+// Checks if an absolute project dir has neither a terragrunt config nor one of the `--project-hcl-files`
+func isOrphanDir(dir string) bool {
+	if firstExistingFile(dir, config.DefaultTerragruntConfigPaths) != "" {
+		return false
+	}
+	return firstExistingFile(dir, projectHclFiles) == ""
+}
+
+// Returns the deepest directory containing all of the given absolute dirs, with a trailing separator
+func commonAncestorDir(dirs []string) string {
+	ancestor := filepath.Clean(dirs[0])
+	for _, dir := range dirs[1:] {
+		for {
+			relativeDir, err := filepath.Rel(ancestor, dir)
+			if err == nil && relativeDir != ".." && !strings.HasPrefix(relativeDir, ".."+string(filepath.Separator)) {
+				break
+			}
+			ancestor = filepath.Dir(ancestor)
+		}
+	}
+	return strings.TrimSuffix(ancestor, string(filepath.Separator)) + string(filepath.Separator)
+}
+
+// Finds the nearest directory at or above dir containing `.git`, which is a directory in a regular checkout and a file
+// in worktrees and submodules
+func findGitRoot(dir string) (string, error) {
+	for current := filepath.Clean(dir); ; current = filepath.Dir(current) {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current, nil
+		}
+		if filepath.Dir(current) == current {
+			return "", fmt.Errorf("no .git found in %s or any directory above it", filepath.Clean(dir))
+		}
+	}
+}
+
 func lookupProjectHcl(m map[string][]string, value string) (key string) {
 	for k, values := range m {
 		for _, val := range values {
@@ -115,6 +547,7 @@ func sliceUnion(a, b []string) []string {
 
 	for _, item := range b {
 		if _, ok := m[item]; !ok {
+			m[item] = true
 			a = append(a, item)
 		}
 	}
@@ -123,33 +556,62 @@ func sliceUnion(a, b []string) []string {
 
 // Parses the terragrunt config at `path` to find all modules it depends on
 func getDependencies(ctx *config.ParsingContext, path string) ([]string, error) {
-	res, err, _ := requestGroup.Do(path, func() (interface{}, error) {
+	cacheKey := dependenciesCacheKey(path)
+	res, err, _ := requestGroup.Do(cacheKey, func() (interface{}, error) {
 		// Check if this path has already been computed
-		cachedResult, ok := getDependenciesCache.get(path)
+		cachedResult, ok := getDependenciesCache.get(cacheKey)
 		if ok {
+			stats.dependencyCacheHits.Add(1)
 			return cachedResult.dependencies, cachedResult.err
 		}
+		stats.dependenciesResolved.Add(1)
 
 		// parse the module path to find what it includes, as well as its potential to be a parent
 		// return nils to indicate we should skip this project
 		isParent, includes, err := parseModule(ctx, path)
 		if err != nil {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, err})
+			getDependenciesCache.set(cacheKey, getDependenciesOutput{nil, err})
 			return nil, err
 		}
 		if isParent && ignoreParentTerragrunt {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, nil})
+			getDependenciesCache.set(cacheKey, getDependenciesOutput{nil, nil})
 			return nil, nil
 		}
 
+		if err := checkIncludeCycle(ctx, []string{filepath.Clean(path)}, includes); err != nil {
+			getDependenciesCache.set(cacheKey, getDependenciesOutput{nil, err})
+			return nil, err
+		}
+
 		dependencies := []string{}
 		if len(includes) > 0 {
 			for _, includeDep := range includes {
-				getDependenciesCache.set(includeDep.Path, getDependenciesOutput{nil, err})
+				// Included configs are parents, except for a runnable root config kept by `--include-root-project`
+				if !isRootProjectConfig(includeDep.Path) {
+					getDependenciesCache.set(dependenciesCacheKey(includeDep.Path), getDependenciesOutput{nil, err})
+				}
 				dependencies = append(dependencies, includeDep.Path)
 			}
 		}
 
+		// Files read by the `generate` blocks of the included configs and the module itself change the generated code
+		generatingConfigs := []string{}
+		for _, includeDep := range includes {
+			includePath := includeDep.Path
+			if !filepath.IsAbs(includePath) {
+				includePath = makePathAbsolute(includePath, path)
+			}
+			generatingConfigs = append(generatingConfigs, includePath)
+		}
+		for _, generatingConfig := range append(generatingConfigs, path) {
+			dependencies = append(dependencies, findGenerateBlockFiles(generatingConfig, path)...)
+		}
+		if trackReadFiles {
+			for _, readingConfig := range append(generatingConfigs, path) {
+				dependencies = append(dependencies, findReadConfigFiles(readingConfig, path)...)
+			}
+		}
+
 		// Parse the HCL file
 		parseCtx := config.NewParsingContext(ctx, ctx.TerragruntOptions).
 			WithDecodeList(
@@ -159,14 +621,14 @@ func getDependencies(ctx *config.ParsingContext, path string) ([]string, error)
 			)
 		parsedConfig, err := config.PartialParseConfigFile(parseCtx, path, nil)
 		if err != nil {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, err})
+			getDependenciesCache.set(cacheKey, getDependenciesOutput{nil, err})
 			return nil, err
 		}
 
 		// Parse out locals
 		locals, err := parseLocals(ctx, path, nil)
 		if err != nil {
-			getDependenciesCache.set(path, getDependenciesOutput{nil, err})
+			getDependenciesCache.set(cacheKey, getDependenciesOutput{nil, err})
 			return nil, err
 		}
 
@@ -179,17 +641,31 @@ func getDependencies(ctx *config.ParsingContext, path string) ([]string, error)
 		if parsedConfig.Dependencies != nil && !ignoreDependencyBlocks {
 			for _, parsedPaths := range parsedConfig.Dependencies.Paths {
 				dependencies = append(dependencies, filepath.Join(parsedPaths, "terragrunt.hcl"))
+
+				dependencyDir := parsedPaths
+				if !filepath.IsAbs(dependencyDir) {
+					dependencyDir = filepath.Join(filepath.Dir(path), dependencyDir)
+				}
+				if firstExistingFile(dependencyDir, config.DefaultTerragruntConfigPaths) == "" {
+					diagnostics.Warnf(path, "dependency %s has no terragrunt config", parsedPaths)
+				}
 			}
 		}
 
-		// Get deps from the `Source` field of the `Terraform` block
+		// Get deps from the `Source` field of the `Terraform` block. The parsed config already has the blocks of its
+		// includes merged in, and like in Terragrunt, relative sources are relative to the child module even when they
+		// are inherited from an include.
 		if parsedConfig.Terraform != nil && parsedConfig.Terraform.Source != nil {
 			source := parsedConfig.Terraform.Source
 
-			// Use `go-getter` to normalize the source paths
-			parsedSource, err := getter.Detect(*source, filepath.Dir(path), getter.Detectors)
-			if err != nil {
-				return nil, err
+			// Use `go-getter` to normalize the source paths. Registry sources are always remote, but go-getter
+			// has no detector for them, so they are left untouched.
+			parsedSource := *source
+			if !isRegistryModuleSource(*source) {
+				parsedSource, err = detectModuleSource(*source, filepath.Dir(path), moduleSourceDetectors)
+				if err != nil {
+					return nil, err
+				}
 			}
 
 			// Check if the path begins with a drive letter, denoting Windows
@@ -200,10 +676,13 @@ func getDependencies(ctx *config.ParsingContext, path string) ([]string, error)
 
 			// If the normalized source begins with `file://`, or matched the Windows drive letter check, it is a local path
 			if strings.HasPrefix(parsedSource, "file://") || isWindowsPath {
-				// Remove the prefix so we have a valid filesystem path
-				parsedSource = strings.TrimPrefix(parsedSource, "file://")
+				// Remove the prefix and any query string so we have a valid filesystem path
+				parsedSource = stripModuleSourceQuery(strings.TrimPrefix(parsedSource, "file://"))
 
 				dependencies = append(dependencies, filepath.Join(parsedSource, "*.tf*"))
+				if hasTofuFiles(parsedSource) {
+					dependencies = append(dependencies, filepath.Join(parsedSource, tofuFilesGlob))
+				}
 
 				ls, err := parseTerraformLocalModuleSource(parsedSource)
 				if err != nil {
@@ -212,6 +691,23 @@ func getDependencies(ctx *config.ParsingContext, path string) ([]string, error)
 				sort.Strings(ls)
 
 				dependencies = append(dependencies, ls...)
+			} else if resolveRemoteLocalSubmodules {
+				// Remote modules are never fetched, but if Terragrunt already vendored a copy we can still follow
+				// the local module calls inside of it
+				vendoredDir, workingDirCache, err := findVendoredModuleDir(filepath.Dir(path), parsedSource)
+				if err != nil {
+					return nil, err
+				}
+				if vendoredDir != "" {
+					ls, err := parseTerraformLocalModuleSource(vendoredDir)
+					if err != nil {
+						return nil, err
+					}
+					ls = stableVendoredPaths(ls, workingDirCache)
+					sort.Strings(ls)
+
+					dependencies = append(dependencies, ls...)
+				}
 			}
 		}
 
@@ -238,13 +734,14 @@ func getDependencies(ctx *config.ParsingContext, path string) ([]string, error)
 		// Filter out and dependencies that are the empty string
 		nonEmptyDeps := []string{}
 		for _, dep := range dependencies {
+			negation, dep := splitNegation(dep)
 			if dep != "" {
 				childDepAbsPath := dep
 				if !filepath.IsAbs(childDepAbsPath) {
 					childDepAbsPath = makePathAbsolute(dep, path)
 				}
 				childDepAbsPath = filepath.ToSlash(childDepAbsPath)
-				nonEmptyDeps = append(nonEmptyDeps, childDepAbsPath)
+				nonEmptyDeps = append(nonEmptyDeps, negation+childDepAbsPath)
 			}
 		}
 
@@ -253,8 +750,8 @@ func getDependencies(ctx *config.ParsingContext, path string) ([]string, error)
 		for _, dep := range nonEmptyDeps {
 			cascadedDeps = append(cascadedDeps, dep)
 
-			// The "cascading" feature is protected by a flag
-			if !cascadeDependencies {
+			// The "cascading" feature is protected by a flag. Negated entries exclude files instead of naming a module.
+			if !cascadeDependencies || strings.HasPrefix(dep, negatedDependencyPrefix) {
 				continue
 			}
 
@@ -276,7 +773,7 @@ func getDependencies(ctx *config.ParsingContext, path string) ([]string, error)
 				if !filepath.IsAbs(childDep) {
 					childDepAbsPath, err = filepath.Abs(filepath.Join(depPath, "..", childDep))
 					if err != nil {
-						getDependenciesCache.set(path, getDependenciesOutput{nil, err})
+						getDependenciesCache.set(cacheKey, getDependenciesOutput{nil, err})
 						return nil, err
 					}
 				}
@@ -296,7 +793,7 @@ func getDependencies(ctx *config.ParsingContext, path string) ([]string, error)
 			}
 		}
 
-		if filepath.Base(path) == "terragrunt.hcl" {
+		if util.ListContainsElement(config.DefaultTerragruntConfigPaths, filepath.Base(path)) {
 			dir := filepath.Dir(path)
 
 			ls, err := parseTerraformLocalModuleSource(dir)
@@ -308,7 +805,7 @@ func getDependencies(ctx *config.ParsingContext, path string) ([]string, error)
 			cascadedDeps = append(cascadedDeps, ls...)
 		}
 
-		getDependenciesCache.set(path, getDependenciesOutput{cascadedDeps, err})
+		getDependenciesCache.set(cacheKey, getDependenciesOutput{cascadedDeps, err})
 		return cascadedDeps, nil
 	})
 
@@ -321,6 +818,16 @@ func getDependencies(ctx *config.ParsingContext, path string) ([]string, error)
 
 // Creates an AtlantisProject for a directory
 func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, error) {
+	if traceSlowModules > 0 {
+		defer stats.recordModuleDuration(sourcePath, time.Now())
+	}
+
+	// Oversized configs are usually generated or malformed, and parsing them can use huge amounts of memory
+	if exceedsMaxFileSize(sourcePath) {
+		diagnostics.Warnf(sourcePath, "skipped as it is larger than --max-file-size of %d bytes", maxFileSize)
+		return nil, nil
+	}
+
 	options, err := options.NewTerragruntOptionsWithConfigPath(sourcePath)
 	if err != nil {
 		return nil, err
@@ -356,8 +863,14 @@ func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, er
 		"*.tf*",
 	}
 
+	// JSON configs like `terragrunt.hcl.json` aren't matched by `*.hcl`
+	if filepath.Ext(sourcePath) == ".json" {
+		relativeDependencies = append(relativeDependencies, "*.hcl.json")
+	}
+
 	// Add other dependencies based on their relative paths. We always want to output with Unix path separators
 	for _, dependencyPath := range dependencies {
+		negation, dependencyPath := splitNegation(dependencyPath)
 		absolutePath := dependencyPath
 		if !filepath.IsAbs(absolutePath) {
 			absolutePath = makePathAbsolute(dependencyPath, sourcePath)
@@ -367,7 +880,7 @@ func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, er
 			return nil, err
 		}
 
-		relativeDependencies = append(relativeDependencies, filepath.ToSlash(relativePath))
+		relativeDependencies = append(relativeDependencies, negation+filepath.ToSlash(relativePath))
 	}
 
 	// Clean up the relative path to the format Atlantis expects
@@ -377,19 +890,13 @@ func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, er
 		relativeSourceDir = "."
 	}
 
+	projectDir, whenModified := liftProjectDir(filepath.ToSlash(relativeSourceDir), negationsLast(uniqueStrings(relativeDependencies)), projectDirLiftLevels(filepath.ToSlash(relativeSourceDir)))
+
 	workflow := defaultWorkflow
 	if locals.AtlantisWorkflow != "" {
 		workflow = locals.AtlantisWorkflow
 	}
 
-	applyRequirements := &defaultApplyRequirements
-	if len(defaultApplyRequirements) == 0 {
-		applyRequirements = nil
-	}
-	if locals.ApplyRequirements != nil {
-		applyRequirements = &locals.ApplyRequirements
-	}
-
 	resolvedAutoPlan := autoPlan
 	if locals.AutoPlan != nil {
 		resolvedAutoPlan = *locals.AutoPlan
@@ -400,15 +907,27 @@ func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, er
 		terraformVersion = locals.TerraformVersion
 	}
 
+	repoLocks, err := resolveRepoLocks(locals)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", sourcePath, err)
+	}
+
 	project := &AtlantisProject{
-		Dir:               filepath.ToSlash(relativeSourceDir),
-		Workflow:          workflow,
+		Dir:               projectDir,
+		Workflow:          decorateWorkflowName(workflow),
 		TerraformVersion:  terraformVersion,
-		ApplyRequirements: applyRequirements,
+		ApplyRequirements: resolveApplyRequirements(locals),
+		PlanRequirements:  resolvePlanRequirements(locals),
+		RepoLocks:         repoLocks,
 		Autoplan: AutoplanConfig{
 			Enabled:      resolvedAutoPlan,
-			WhenModified: uniqueStrings(relativeDependencies),
+			WhenModified: whenModified,
 		},
+		Tags:                projectTags(locals),
+		customWorkflow:      locals.WorkflowSteps,
+		description:         locals.Description,
+		executionOrderGroup: locals.ExecutionOrderGroup,
+		isolationGroup:      locals.IsolationGroup,
 	}
 
 	// Terraform Cloud limits the workspace names to be less than 90 characters
@@ -423,11 +942,22 @@ func createProject(ctx context.Context, sourcePath string) (*AtlantisProject, er
 	if createProjectName {
 		project.Name = projectName
 	}
+	if locals.ProjectName != "" {
+		project.Name = locals.ProjectName
+	}
 
 	if createWorkspace {
 		project.Workspace = projectName
 	}
 
+	if workspaceTemplate != nil {
+		workspace, err := renderWorkspace(project.Dir, locals)
+		if err != nil {
+			return nil, err
+		}
+		project.Workspace = workspace
+	}
+
 	return project, nil
 }
 
@@ -435,7 +965,6 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 	var projectHclDependencies []string
 	var childDependencies []string
 	workflow := defaultWorkflow
-	applyRequirements := &defaultApplyRequirements
 	resolvedAutoPlan := autoPlan
 	terraformVersion := defaultTerraformVersion
 
@@ -468,11 +997,16 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 
 	if locals.ExtraAtlantisDependencies != nil {
 		for _, dep := range locals.ExtraAtlantisDependencies {
+			negation, dep := splitNegation(dep)
+			// Relative dependencies are relative to the project hcl file, the same as for terragrunt modules
+			if !filepath.IsAbs(dep) {
+				dep = makePathAbsolute(dep, projectHclFile)
+			}
 			relDep, err := filepath.Rel(workingDir, dep)
 			if err != nil {
 				return nil, err
 			}
-			projectHclDependencies = append(projectHclDependencies, filepath.ToSlash(relDep))
+			projectHclDependencies = append(projectHclDependencies, negation+filepath.ToSlash(relDep))
 		}
 	}
 
@@ -480,13 +1014,6 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 		workflow = locals.AtlantisWorkflow
 	}
 
-	if len(defaultApplyRequirements) == 0 {
-		applyRequirements = nil
-	}
-	if locals.ApplyRequirements != nil {
-		applyRequirements = &locals.ApplyRequirements
-	}
-
 	if locals.AutoPlan != nil {
 		resolvedAutoPlan = *locals.AutoPlan
 	}
@@ -495,6 +1022,11 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 		terraformVersion = locals.TerraformVersion
 	}
 
+	repoLocks, err := resolveRepoLocks(locals)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", projectHclFile, err)
+	}
+
 	// build dependencies for terragrunt childs in directories below project hcl file
 	for _, sourcePath := range sourcePaths {
 		opt, err := options.NewTerragruntOptionsWithConfigPath(sourcePath)
@@ -522,6 +1054,7 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 
 		// Add other dependencies based on their relative paths. We always want to output with Unix path separators
 		for _, dependencyPath := range dependencies {
+			negation, dependencyPath := splitNegation(dependencyPath)
 			absolutePath := dependencyPath
 			if !filepath.IsAbs(absolutePath) {
 				absolutePath = makePathAbsolute(dependencyPath, sourcePath)
@@ -532,8 +1065,11 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 				return nil, err
 			}
 
-			if !strings.Contains(absolutePath, filepath.ToSlash(workingDir)) {
-				relativeDependencies = append(relativeDependencies, filepath.ToSlash(relativePath))
+			// Dependencies below the project dir are already matched by the `**` globs. This compares whole path
+			// segments, so a sibling like `app-network` is not mistaken for being below `app`. Negated entries are
+			// always kept, as they exclude files from those globs.
+			if negation != "" || !util.HasPathPrefix(absolutePath, workingDir) {
+				relativeDependencies = append(relativeDependencies, negation+filepath.ToSlash(relativePath))
 			}
 		}
 
@@ -546,13 +1082,21 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 
 	project := &AtlantisProject{
 		Dir:               filepath.ToSlash(dir),
-		Workflow:          workflow,
+		Workflow:          decorateWorkflowName(workflow),
 		TerraformVersion:  terraformVersion,
-		ApplyRequirements: applyRequirements,
+		ApplyRequirements: resolveApplyRequirements(locals),
+		PlanRequirements:  resolvePlanRequirements(locals),
+		RepoLocks:         repoLocks,
 		Autoplan: AutoplanConfig{
 			Enabled:      resolvedAutoPlan,
-			WhenModified: uniqueStrings(append(childDependencies, projectHclDependencies...)),
+			WhenModified: negationsLast(uniqueStrings(append(childDependencies, projectHclDependencies...))),
 		},
+		Tags:                projectTags(locals),
+		customWorkflow:      locals.WorkflowSteps,
+		description:         locals.Description,
+		executionOrderGroup: locals.ExecutionOrderGroup,
+		isolationGroup:      locals.IsolationGroup,
+		hclProject:          true,
 	}
 
 	// Terraform Cloud limits the workspace names to be less than 90 characters
@@ -567,21 +1111,27 @@ func createHclProject(ctx context.Context, sourcePaths []string, workingDir stri
 	if createProjectName {
 		project.Name = projectName
 	}
+	if locals.ProjectName != "" {
+		project.Name = locals.ProjectName
+	}
 
 	if createWorkspace {
 		project.Workspace = projectName
 	}
 
+	if workspaceTemplate != nil {
+		workspace, err := renderWorkspace(project.Dir, locals)
+		if err != nil {
+			return nil, err
+		}
+		project.Workspace = workspace
+	}
+
 	return project, nil
 }
 
 // Finds the absolute paths of all terragrunt.hcl files
-func getAllTerragruntFiles(path string) ([]string, error) {
-	options, err := options.NewTerragruntOptionsWithConfigPath(path)
-	if err != nil {
-		return nil, err
-	}
-
+func getAllTerragruntFiles(path string, discovered *discoveredFiles) ([]string, error) {
 	// If filterPaths is provided, override workingPath instead of gitRoot
 	// We do this here because we want to keep the relative path structure of Terragrunt files
 	// to root and just ignore the ConfigFiles
@@ -591,8 +1141,8 @@ func getAllTerragruntFiles(path string) ([]string, error) {
 	if len(filterPaths) > 0 && len(projectHclFiles) == 0 {
 		workingPaths = []string{}
 		for _, filterPath := range filterPaths {
-			// get all matching folders
-			theseWorkingPaths, err := filepath.Glob(filterPath)
+			// get all matching folders. `**` matches any number of directories, so `**/mysql` finds them at any depth
+			theseWorkingPaths, err := doublestar.Glob(filterPath)
 			if err != nil {
 				return nil, err
 			}
@@ -603,7 +1153,7 @@ func getAllTerragruntFiles(path string) ([]string, error) {
 	uniqueConfigFilePaths := make(map[string]bool)
 	orderedConfigFilePaths := []string{}
 	for _, workingPath := range workingPaths {
-		paths, err := FindConfigFilesInPath(workingPath, options)
+		paths, err := discovered.configFilesIn(workingPath)
 		if err != nil {
 			return nil, err
 		}
@@ -616,121 +1166,182 @@ func getAllTerragruntFiles(path string) ([]string, error) {
 		}
 	}
 
-	uniqueConfigFileAbsPaths := []string{}
-	for _, uniquePath := range orderedConfigFilePaths {
-		uniqueAbsPath, err := filepath.Abs(uniquePath)
-		if err != nil {
-			return nil, err
-		}
-		uniqueConfigFileAbsPaths = append(uniqueConfigFileAbsPaths, uniqueAbsPath)
+	return orderedConfigFilePaths, nil
+}
+
+// FindConfigFilesInPath returns a list of all Terragrunt config files in the given path or any subfolder of the path,
+// including root configs like root.hcl
+//
+// Deprecated: opts is ignored, use DiscoverConfigFiles instead.
+func FindConfigFilesInPath(rootPath string, opts *options.TerragruntOptions) ([]string, error) {
+	return DiscoverConfigFiles(rootPath)
+}
+
+// DiscoverConfigFiles returns a list of all Terragrunt config files in the given path or any subfolder of the path,
+// including root configs like root.hcl, in the single walk that also finds the project hcl files
+func DiscoverConfigFiles(rootPath string) ([]string, error) {
+	discovered, err := discoverFiles(rootPath)
+	if err != nil {
+		return nil, err
 	}
 
-	return uniqueConfigFileAbsPaths, nil
+	return discovered.configFiles, nil
 }
 
-// FindConfigFilesInPath returns a list of all Terragrunt config files in the given path or any subfolder of the path. A file is a Terragrunt
-// config file if it has a name as returned by the DefaultConfigPath method
-func FindConfigFilesInPath(rootPath string, opts *options.TerragruntOptions) ([]string, error) {
-	configFiles := []string{}
+// Finds the absolute paths of the directories containing each of the arbitrary project hcl files
+func getAllTerragruntProjectHclFiles(discovered *discoveredFiles) map[string][]string {
+	uniqueHclFileAbsPaths := map[string][]string{}
+	for _, projectHclFile := range projectHclFiles {
+		uniqueHclFileAbsPaths[projectHclFile] = discovered.projectHclDirs[projectHclFile]
+	}
+	return uniqueHclFileAbsPaths
+}
 
-	walkFunc := filepath.Walk
+func main(cmd *cobra.Command, args []string) error {
+	if err := applyEnvVars(cmd.Flags()); err != nil {
+		return err
+	}
+	level, err := log.ParseLevel(logLevel)
+	if err != nil {
+		return fmt.Errorf("invalid --log-level: %w", err)
+	}
+	if quiet && cmd.Flags().Changed("log-level") {
+		return fmt.Errorf("--quiet can't be used with --log-level")
+	}
+	if quiet {
+		level = log.ErrorLevel
+	}
+	log.SetLevel(level)
 
-	err := walkFunc(rootPath, func(path string, info os.FileInfo, err error) error {
+	stopProfiling, err := startProfiling()
+	if err != nil {
+		return err
+	}
+	defer stopProfiling()
+
+	diagnostics = &Diagnostics{}
+	stats = &runStats{started: time.Now()}
+	// Included configs may have changed since a previous run in this process, and are checked again with its flags
+	acyclicIncludesCache = newAcyclicIncludesCache()
+
+	// Ensure every root has a trailing slash and is an absolute path. Project dirs are made relative to gitRoot, the
+	// deepest directory containing all of the roots.
+	roots := []string{}
+	for _, root := range gitRoots {
+		absoluteRoot, err := filepath.Abs(root)
 		if err != nil {
 			return err
 		}
-
-		if !info.IsDir() {
-			return nil
+		roots = append(roots, absoluteRoot+string(filepath.Separator))
+	}
+	rootDirs = roots
+	gitRoot = commonAncestorDir(roots)
+	if detectGitRoot && baseDir == "" {
+		detectedGitRoot, err := findGitRoot(gitRoot)
+		if err != nil {
+			return fmt.Errorf("--detect-git-root: %w", err)
 		}
+		gitRoot = strings.TrimSuffix(detectedGitRoot, string(filepath.Separator)) + string(filepath.Separator)
+	}
+	if baseDir != "" {
+		absoluteBaseDir, err := filepath.Abs(baseDir)
+		if err != nil {
+			return err
+		}
+		gitRoot = absoluteBaseDir + string(filepath.Separator)
 
-		for _, configFile := range []string{"root.hcl"} {
-			if !filepath.IsAbs(configFile) {
-				configFile = util.JoinPath(path, configFile)
-			}
-
-			if !util.IsDir(configFile) && util.FileExists(configFile) {
-				configFiles = append(configFiles, configFile)
-				break
+		for _, root := range roots {
+			if !util.HasPathPrefix(root, gitRoot) {
+				return fmt.Errorf("--root %s is not inside --base-dir %s", filepath.Clean(root), absoluteBaseDir)
 			}
 		}
+	}
 
-		return nil
-	})
-
-	nestedConfigFiles, err := config.FindConfigFilesInPath(rootPath, opts)
-	if err == nil {
-		configFiles = append(configFiles, nestedConfigFiles...)
+	if err := validateManage(); err != nil {
+		return err
 	}
-	return configFiles, nil
-}
 
-// Finds the absolute paths of all arbitrary project hcl files
-func getAllTerragruntProjectHclFiles() map[string][]string {
-	projectHclFiles := projectHclFiles
-	orderedHclFilePaths := map[string][]string{}
-	uniqueHclFileAbsPaths := map[string][]string{}
-	for _, projectHclFile := range projectHclFiles {
-		err := filepath.Walk(gitRoot, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
+	if err := validateSummary(); err != nil {
+		return err
+	}
+	if err := validateTerragruntCompat(); err != nil {
+		return err
+	}
+	if err := validateAutoplanMode(); err != nil {
+		return err
+	}
 
-			if !info.IsDir() && info.Name() == projectHclFile {
-				orderedHclFilePaths[projectHclFile] = append(orderedHclFilePaths[projectHclFile], filepath.Dir(path))
-			}
+	// Projects were always ordered by execution order group when computing them, so keep that unless asked otherwise
+	if executionOrderGroups && !cmd.Flags().Changed("sort-projects-by") {
+		sortProjectsBy = "execution-order"
+	}
+	if err := validateSortProjectsBy(); err != nil {
+		return err
+	}
+	if err := validateAtlantisVersion(); err != nil {
+		return err
+	}
+	if outputProjectsOnly && postProcess != "" {
+		return fmt.Errorf("--output-projects-only can't be used with --post-process, which needs a whole config")
+	}
+	if groupByDepth < 0 {
+		return fmt.Errorf("--group-by-depth must not be negative, not %d", groupByDepth)
+	}
+	if groupByDepth > 0 && projectDirLevels > 0 {
+		return fmt.Errorf("--group-by-depth and --include-parent-in-project-dir can't be used together")
+	}
+	if executionOrderStep < 1 {
+		return fmt.Errorf("--execution-order-step must be at least 1, not %d", executionOrderStep)
+	}
+	if mergeExistingProjectsByName && !preserveProjects {
+		return fmt.Errorf("--merge-existing-projects-by-name requires --preserve-projects")
+	}
 
-			return nil
-		})
+	if err := parseWorkspaceTemplate(); err != nil {
+		return err
+	}
 
+	// Patterns of `--filter-file` are used in addition to the ones given with `--filter`
+	if filterFile != "" {
+		patterns, err := readFilterFile(filterFile)
 		if err != nil {
-			log.Fatal(err)
+			return fmt.Errorf("could not read --filter-file: %w", err)
 		}
+		filterPaths = append(append([]string{}, filterPaths...), patterns...)
+	}
 
-		for _, uniquePath := range orderedHclFilePaths[projectHclFile] {
-			uniqueAbsPath, err := filepath.Abs(uniquePath)
-			if err != nil {
-				return nil
-			}
-			uniqueHclFileAbsPaths[projectHclFile] = append(uniqueHclFileAbsPaths[projectHclFile], uniqueAbsPath)
+	if repoConfigHcl != "" {
+		if err := applyRepoConfigHcl(cmd.Context(), cmd.Flags()); err != nil {
+			return err
 		}
 	}
-	return uniqueHclFileAbsPaths
-}
 
-func main(cmd *cobra.Command, args []string) error {
-	// Ensure the gitRoot has a trailing slash and is an absolute path
-	absoluteGitRoot, err := filepath.Abs(gitRoot)
+	if repoLocksMode != "" {
+		if err := validateRepoLocksMode(repoLocksMode, "--repo-locks-mode"); err != nil {
+			return err
+		}
+	}
+
+	updateOnlyDirs, err = resolveUpdateOnlyDirs()
 	if err != nil {
 		return err
 	}
-	gitRoot = absoluteGitRoot + string(filepath.Separator)
-	workingDirs := []string{gitRoot}
-	projectHclDirMap := map[string][]string{}
-	var projectHclDirs []string
-	if len(projectHclFiles) > 0 {
-		workingDirs = nil
-		// map [project-hcl-file] => directories containing project-hcl-file
-		projectHclDirMap = getAllTerragruntProjectHclFiles()
-		for _, projectHclFile := range projectHclFiles {
-			projectHclDirs = append(projectHclDirs, projectHclDirMap[projectHclFile]...)
-			workingDirs = append(workingDirs, projectHclDirMap[projectHclFile]...)
-		}
-		// parse terragrunt child modules outside the scope of projectHclDirs
-		if createHclProjectExternalChilds {
-			workingDirs = append(workingDirs, gitRoot)
-		}
-	}
+
 	// Read in the old config, if it already exists
 	oldConfig, err := readOldConfig()
 	if err != nil {
 		return err
 	}
+	if len(updateOnlyDirs) > 0 && oldConfig == nil {
+		return fmt.Errorf("--update-only needs an existing config to keep the other projects from, but none was found at %q", existingConfigPath())
+	}
 	config := AtlantisConfig{
-		Version:       3,
-		AutoMerge:     autoMerge,
-		ParallelPlan:  parallel,
-		ParallelApply: parallel,
+		Version:              3,
+		AutoMerge:            autoMerge,
+		ParallelPlan:         parallel,
+		ParallelApply:        parallel,
+		AllowedOverrides:     allowedOverrides,
+		AllowCustomWorkflows: allowCustomWorkflows,
 	}
 	if oldConfig != nil && preserveWorkflows {
 		config.Workflows = oldConfig.Workflows
@@ -738,43 +1349,171 @@ func main(cmd *cobra.Command, args []string) error {
 	if oldConfig != nil && preserveProjects {
 		config.Projects = oldConfig.Projects
 	}
+	// Projects outside of the `--update-only` dirs are kept as they are, the ones inside are all generated again
+	if len(updateOnlyDirs) > 0 {
+		config.Projects = nil
+		for _, project := range oldConfig.Projects {
+			if !isUpdatedDir(filepath.Join(gitRoot, project.Dir)) {
+				config.Projects = append(config.Projects, project)
+			}
+		}
+	}
+	// Generated projects replace these, so the ones still marked afterwards are only kept from the existing config
+	for i := range config.Projects {
+		config.Projects[i].preserved = true
+	}
 
 	lock := sync.Mutex{}
+	liftedDirs := map[string]bool{}
+	// The root each project dir was generated from, as projects of different roots must not share a dir
+	projectRoots := map[string]string{}
+	claimProjectDir := func(dir string, root string) error {
+		if otherRoot, ok := projectRoots[dir]; ok && otherRoot != root {
+			return fmt.Errorf("project dir %s is generated from both --root %s and --root %s", dir, filepath.Clean(otherRoot), filepath.Clean(root))
+		}
+		projectRoots[dir] = root
+		return nil
+	}
 	ctx := context.Background()
 	errGroup, _ := errgroup.WithContext(ctx)
 	sem := semaphore.NewWeighted(numExecutors)
 
-	for _, workingDir := range workingDirs {
-		terragruntFiles, err := getAllTerragruntFiles(workingDir)
+	for _, root := range roots {
+		// Walk each root once, all later lookups of config and project hcl files are served from this
+		discovered, err := discoverFiles(root)
 		if err != nil {
 			return err
 		}
+		for _, configFile := range discovered.configFiles {
+			if !util.ListContainsElement(rootConfigFiles(), filepath.Base(configFile)) {
+				stats.modulesDiscovered.Add(1)
+			}
+		}
+		workingDirs := []string{root}
+		projectHclDirMap := map[string][]string{}
+		var projectHclDirs []string
+		if len(projectHclFiles) > 0 {
+			workingDirs = nil
+			// map [project-hcl-file] => directories containing project-hcl-file
+			projectHclDirMap = getAllTerragruntProjectHclFiles(discovered)
+			for _, projectHclFile := range projectHclFiles {
+				projectHclDirs = append(projectHclDirs, projectHclDirMap[projectHclFile]...)
+				workingDirs = append(workingDirs, projectHclDirMap[projectHclFile]...)
+			}
+			// parse terragrunt child modules outside the scope of projectHclDirs
+			if createHclProjectExternalChilds {
+				workingDirs = append(workingDirs, root)
+			}
+		}
+		for _, workingDir := range workingDirs {
+			terragruntFiles, err := getAllTerragruntFiles(workingDir, discovered)
+			if err != nil {
+				return err
+			}
 
-		if len(projectHclDirs) == 0 || createHclProjectChilds || (createHclProjectExternalChilds && workingDir == gitRoot) {
-			// Concurrently looking all dependencies
-			for _, terragruntPath := range terragruntFiles {
-				terragruntPath := terragruntPath // https://golang.org/doc/faq#closures_and_goroutines
-
-				// don't create atlantis projects already covered by project hcl file projects
-				skipProject := false
-				if createHclProjectExternalChilds && workingDir == gitRoot && len(projectHclDirs) > 0 {
-					for _, projectHclDir := range projectHclDirs {
-						if strings.HasPrefix(terragruntPath, projectHclDir) {
-							skipProject = true
-							break
+			if len(projectHclDirs) == 0 || createHclProjectChilds || (createHclProjectExternalChilds && workingDir == root) {
+				// Concurrently looking all dependencies
+				for _, terragruntPath := range terragruntFiles {
+					terragruntPath := terragruntPath // https://golang.org/doc/faq#closures_and_goroutines
+
+					// don't create atlantis projects already covered by project hcl file projects
+					skipProject := false
+					if createHclProjectExternalChilds && workingDir == root && len(projectHclDirs) > 0 {
+						for _, projectHclDir := range projectHclDirs {
+							if util.HasPathPrefix(terragruntPath, projectHclDir) {
+								skipProject = true
+								break
+							}
 						}
 					}
+					if skipProject || !isUpdatedDir(filepath.Dir(terragruntPath)) {
+						continue
+					}
+					if err := sem.Acquire(ctx, 1); err != nil {
+						return err
+					}
+
+					errGroup.Go(func() error {
+						defer sem.Release(1)
+						project, err := createProject(ctx, terragruntPath)
+						if err != nil && keepGoing {
+							diagnostics.Warnf(terragruntPath, "skipped as its project could not be generated: %s", err)
+							return nil
+						}
+						if err != nil {
+							return err
+						}
+						// if project and err are nil then skip this project
+						if err == nil && project == nil {
+							return nil
+						}
+						// a module lifted into a parent dir can end up outside of the `--update-only` dirs
+						if !isUpdatedDir(filepath.Join(gitRoot, project.Dir)) {
+							return nil
+						}
+
+						// Lock the list as only one goroutine should be writing to config.Projects at a time
+						lock.Lock()
+						defer lock.Unlock()
+
+						if err := claimProjectDir(project.Dir, root); err != nil {
+							return err
+						}
+
+						// Modules lifted into the same dir by `--include-parent-in-project-dir` or `--group-by-depth` share a
+						// single project
+						if projectDirLevels > 0 || groupByDepth > 0 {
+							if liftedDirs[project.Dir] {
+								for i := range config.Projects {
+									if config.Projects[i].Dir == project.Dir {
+										log.Info("Merged project for ", terragruntPath)
+										return mergeLiftedProject(&config.Projects[i], *project)
+									}
+								}
+							}
+							liftedDirs[project.Dir] = true
+						}
+
+						// When preserving existing projects, we should update existing blocks instead of creating a
+						// duplicate, when generating something which already has representation
+						if preserveProjects {
+							var updateProject bool
+							config.Projects, updateProject = replaceExistingProject(config.Projects, *project)
+							if updateProject {
+								log.Info("Updated project for ", terragruntPath)
+							} else {
+								log.Info("Created project for ", terragruntPath)
+								config.Projects = append(config.Projects, *project)
+							}
+						} else {
+							log.Info("Created project for ", terragruntPath)
+							config.Projects = append(config.Projects, *project)
+						}
+
+						return nil
+					})
+				}
+
+				if err := errGroup.Wait(); err != nil {
+					return err
 				}
-				if skipProject {
+			}
+			if len(projectHclDirs) > 0 && workingDir != root && isUpdatedDir(workingDir) {
+				projectHcl := lookupProjectHcl(projectHclDirMap, workingDir)
+
+				// A project without any terragrunt modules below it would have nothing to plan
+				if len(terragruntFiles) == 0 && !keepEmptyHclProjects {
+					log.Infof("Dropping project for %s as there are no terragrunt modules below it", filepath.Join(workingDir, projectHcl))
 					continue
 				}
-				if err := sem.Acquire(ctx, 1); err != nil {
+				err := sem.Acquire(ctx, 1)
+				if err != nil {
 					return err
 				}
 
 				errGroup.Go(func() error {
 					defer sem.Release(1)
-					project, err := createProject(ctx, terragruntPath)
+					project, err := createHclProject(ctx, terragruntFiles, workingDir, projectHcl)
 					if err != nil {
 						return err
 					}
@@ -782,75 +1521,23 @@ func main(cmd *cobra.Command, args []string) error {
 					if err == nil && project == nil {
 						return nil
 					}
-
 					// Lock the list as only one goroutine should be writing to config.Projects at a time
 					lock.Lock()
 					defer lock.Unlock()
 
-					// When preserving existing projects, we should update existing blocks instead of creating a
-					// duplicate, when generating something which already has representation
-					if preserveProjects {
-						updateProject := false
-
-						// TODO: with Go 1.19, we can replace for loop with slices.IndexFunc for increased performance
-						for i := range config.Projects {
-							if config.Projects[i].Dir == project.Dir {
-								updateProject = true
-								log.Info("Updated project for ", terragruntPath)
-								config.Projects[i] = *project
-
-								// projects should be unique, let's exit for loop for performance
-								// once first occurrence is found and replaced
-								break
-							}
-						}
-
-						if !updateProject {
-							log.Info("Created project for ", terragruntPath)
-							config.Projects = append(config.Projects, *project)
-						}
-					} else {
-						log.Info("Created project for ", terragruntPath)
-						config.Projects = append(config.Projects, *project)
+					if err := claimProjectDir(project.Dir, root); err != nil {
+						return err
 					}
 
+					log.Info("Created "+projectHcl+" project for ", workingDir)
+					config.Projects = append(config.Projects, *project)
+
 					return nil
 				})
-			}
 
-			if err := errGroup.Wait(); err != nil {
-				return err
-			}
-		}
-		if len(projectHclDirs) > 0 && workingDir != gitRoot {
-			projectHcl := lookupProjectHcl(projectHclDirMap, workingDir)
-			err := sem.Acquire(ctx, 1)
-			if err != nil {
-				return err
-			}
-
-			errGroup.Go(func() error {
-				defer sem.Release(1)
-				project, err := createHclProject(ctx, terragruntFiles, workingDir, projectHcl)
-				if err != nil {
+				if err := errGroup.Wait(); err != nil {
 					return err
 				}
-				// if project and err are nil then skip this project
-				if err == nil && project == nil {
-					return nil
-				}
-				// Lock the list as only one goroutine should be writing to config.Projects at a time
-				lock.Lock()
-				defer lock.Unlock()
-
-				log.Info("Created "+projectHcl+" project for ", workingDir)
-				config.Projects = append(config.Projects, *project)
-
-				return nil
-			})
-
-			if err := errGroup.Wait(); err != nil {
-				return err
 			}
 		}
 	}
@@ -858,6 +1545,18 @@ func main(cmd *cobra.Command, args []string) error {
 	// Sort the projects in config by Dir
 	sort.Slice(config.Projects, func(i, j int) bool { return config.Projects[i].Dir < config.Projects[j].Dir })
 
+	if err := registerCustomWorkflows(&config); err != nil {
+		return err
+	}
+
+	if checkOrphans || pruneOrphans {
+		config.Projects = checkOrphanProjects(config.Projects)
+	}
+
+	if err := applyAutoplanMode(config.Projects); err != nil {
+		return err
+	}
+
 	if executionOrderGroups || dependsOn {
 		projectsMap := make(map[string]*AtlantisProject, len(config.Projects))
 		for i := range config.Projects {
@@ -873,24 +1572,33 @@ func main(cmd *cobra.Command, args []string) error {
 				dependsOnList := []string{}
 				// choose order group based on dependencies
 				for _, dep := range project.Autoplan.WhenModified {
-					depPath := filepath.ToSlash(filepath.Dir(filepath.Join(project.Dir, dep)))
-					if depPath == project.Dir {
-						// skip dependency on oneself
+					if strings.HasPrefix(dep, negatedDependencyPrefix) {
+						// negated entries exclude files and are not dependencies
 						continue
 					}
-
-					depProject, ok := projectsMap[depPath]
+					depPath := filepath.ToSlash(filepath.Dir(filepath.Join(project.Dir, dep)))
+					depProject, ok := dependencyProject(projectsMap, depPath)
 					if !ok {
 						// skip not project dependencies
 						continue
 					}
+					if depProject.Dir == project.Dir {
+						// skip dependency on oneself
+						continue
+					}
 					if depProject.ExecutionOrderGroup != nil {
-						if *depProject.ExecutionOrderGroup+1 > executionOrderGroup {
-							executionOrderGroup = *depProject.ExecutionOrderGroup + 1
+						if *depProject.ExecutionOrderGroup+executionOrderStep > executionOrderGroup {
+							executionOrderGroup = *depProject.ExecutionOrderGroup + executionOrderStep
 						}
 					}
 					dependsOnList = append(dependsOnList, depProject.Name)
 				}
+				// several files of a project hcl project can be dependencies
+				dependsOnList = uniqueStrings(dependsOnList)
+				// an `atlantis_execution_order_group` local is used as is, projects depending on it are still ordered after it
+				if project.executionOrderGroup != nil {
+					executionOrderGroup = *project.executionOrderGroup
+				}
 				if projectsMap[project.Dir].ExecutionOrderGroup == nil || *projectsMap[project.Dir].ExecutionOrderGroup != executionOrderGroup {
 					if executionOrderGroups {
 						projectsMap[project.Dir].ExecutionOrderGroup = &executionOrderGroup
@@ -906,25 +1614,49 @@ func main(cmd *cobra.Command, args []string) error {
 
 		if hasChanges {
 			// Should be unreachable
-			log.Warn("Computing execution_order_groups failed. Probably cycle exists")
+			diagnostics.Warnf(gitRoot, "computing execution_order_groups failed. Probably cycle exists")
 		}
-
-		// Sort by execution_order_group
 		if executionOrderGroups {
-			sort.Slice(config.Projects, func(i, j int) bool {
-				if *config.Projects[i].ExecutionOrderGroup == *config.Projects[j].ExecutionOrderGroup {
-					return config.Projects[i].Dir < config.Projects[j].Dir
-				}
-				return *config.Projects[i].ExecutionOrderGroup < *config.Projects[j].ExecutionOrderGroup
-			})
+			separateIsolationGroups(config.Projects)
+		}
+	}
+
+	if err := stripProjectPrefix(config.Projects); err != nil {
+		return err
+	}
+	sortProjects(config.Projects, sortProjectsBy)
+	dropUnsupportedFields(config.Projects)
+
+	if err := checkWorkflowReferences(&config); err != nil {
+		return err
+	}
+	if validateOutput {
+		if err := validateAtlantisConfig(&config); err != nil {
+			return err
 		}
 	}
 
 	// Convert config to YAML string
-	yamlBytes, err := yaml.Marshal(&config)
+	var yamlBytes []byte
+	if outputProjectsOnly {
+		yamlBytes, err = MarshalAtlantisProjects(config.Projects)
+	} else {
+		yamlBytes, err = marshalConfig(&config)
+	}
 	if err != nil {
 		return err
 	}
+	if emitDescriptions {
+		yamlBytes = addProjectDescriptions(yamlBytes, config.Projects)
+	}
+	if postProcess != "" {
+		processedBytes, processedConfig, err := postProcessConfig(ctx, yamlBytes)
+		if err != nil {
+			return err
+		}
+		yamlBytes = processedBytes
+		config = *processedConfig
+	}
 
 	// Ensure newline characters are correct on windows machines, as the json encoding function in the stdlib
 	// uses "\n" for all newlines regardless of OS: https://github.com/golang/go/blob/master/src/encoding/json/stream.go#L211-L217
@@ -933,40 +1665,115 @@ func main(cmd *cobra.Command, args []string) error {
 		yamlString = strings.ReplaceAll(yamlString, "\n", "\r\n")
 	}
 
-	// Write output
-	if len(outputPath) != 0 {
+	// Write output. Logs always go to stderr, so stdout only has the config and can be piped to other tools
+	if outputPath == stdoutOutputPath {
+		fmt.Fprint(cmd.OutOrStdout(), yamlString)
+	} else if len(outputPath) != 0 {
 		os.WriteFile(outputPath, []byte(yamlString), 0644)
+	} else if quiet {
+		// The config is logged at the info level, which `--quiet` hides
+		fmt.Fprint(cmd.OutOrStdout(), yamlString)
 	} else {
 		log.Println(yamlString)
 	}
 
+	diagnostics.LogSummary()
+	if traceSlowModules > 0 {
+		logSlowModules()
+	}
+	if summaryFormat != "" {
+		if err := writeSummary(cmd.ErrOrStderr(), &config); err != nil {
+			return err
+		}
+	}
+	if warnings := len(diagnostics.All()); failOnWarnings && warnings > 0 {
+		return fmt.Errorf("%d warning(s) were emitted while generating the config and --fail-on-warnings is set", warnings)
+	}
+
 	return nil
 }
 
 var gitRoot string
+var gitRoots []string
+var rootDirs []string
+var baseDir string
 var autoPlan bool
+var autoplanMode string
+var validateOutput bool
+var detectGitRoot bool
+var includeSubmodules bool
+var traceSlowModules int
+var allowUndefinedWorkflows bool
+var changedFilesFrom string
 var autoMerge bool
 var ignoreParentTerragrunt bool
 var createParentProject bool
+var includeRootProject bool
+var logLevel string
+var quiet bool
+var trackReadFiles bool
 var ignoreDependencyBlocks bool
 var parallel bool
 var createWorkspace bool
 var createProjectName bool
 var defaultTerraformVersion string
 var defaultWorkflow string
+var workflowPrefix string
+var workflowSuffix string
+var dedupeWorkflows bool
 var filterPaths []string
+var filterFile string
 var outputPath string
+var preserveFrom string
 var preserveWorkflows bool
 var preserveProjects bool
+var mergeExistingProjectsByName bool
+var updateOnly []string
+var checkOrphans bool
+var pruneOrphans bool
+var updateOnlyDirs []string
 var cascadeDependencies bool
 var defaultApplyRequirements []string
+var mergeApplyRequirements bool
+var forceApplyRequirements []string
+var defaultPlanRequirements []string
+var repoLocksMode string
 var numExecutors int64
 var projectHclFiles []string
 var createHclProjectChilds bool
 var createHclProjectExternalChilds bool
 var useProjectMarkers bool
 var executionOrderGroups bool
+var executionOrderStep int
+var atlantisVersion string
+var postProcess string
 var dependsOn bool
+var offline bool
+var resolveRemoteLocalSubmodules bool
+var maxFileSize int64
+var followSymlinks bool
+var failOnWarnings bool
+var repoConfigHcl string
+var manage []string
+var allowedOverrides []string
+var allowCustomWorkflows bool
+var keepEmptyHclProjects bool
+var emitDescriptions bool
+var workspaceTemplateText string
+var sortProjectsBy string
+var terragruntCompat string
+var projectDirLevels int
+var groupByDepth int
+var stripPrefix string
+var stripPrefixNames bool
+var outputProjectsOnly bool
+var keepGoing bool
+var relativizeExtraDependencies bool
+var defaultTags []string
+var summaryFormat string
+var cpuProfilePath string
+var tracePath string
+var ignoreTfParseErrors bool
 
 // generateCmd represents the generate command
 var generateCmd = &cobra.Command{
@@ -983,6 +1790,19 @@ var generateCmd = &cobra.Command{
 	RunE: main,
 }
 
+// Flag names that are aliases of another flag, for names users know from similar tools. An alias sets the same flag,
+// so values given to both are combined like when the flag is given twice.
+var flagAliases = map[string]string{
+	"default-apply-requirements": "apply-requirements",
+}
+
+func normalizeFlagAliases(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+	if flagName, ok := flagAliases[name]; ok {
+		name = flagName
+	}
+	return pflag.NormalizedName(name)
+}
+
 func init() {
 	rootCmd.AddCommand(generateCmd)
 
@@ -992,35 +1812,104 @@ func init() {
 	}
 
 	generateCmd.PersistentFlags().BoolVar(&autoPlan, "autoplan", false, "Enable auto plan. Default is disabled")
+	generateCmd.PersistentFlags().BoolVar(&allowUndefinedWorkflows, "allow-undefined-workflows", false, "Allow project workflows that are not defined in the generated or preserved workflows, as they are defined in the server side repo config. Default is to fail")
+	generateCmd.PersistentFlags().IntVar(&traceSlowModules, "trace-slow-modules", 0, "Log how long parsing the N slowest modules and resolving their dependencies took, to find the modules slowing down a run. Default is 0, not timing modules")
+	generateCmd.PersistentFlags().BoolVar(&validateOutput, "validate-output", false, "Fail instead of writing a config that Atlantis would refuse to load, like one with duplicate project names or workflows that are not defined. Default is false")
+	generateCmd.PersistentFlags().StringVar(&autoplanMode, "autoplan-mode", "", "Autoplan preset for all projects, overriding --autoplan and locals: off, on, or changed to only autoplan the projects affected by --changed-files-from. Default is to use --autoplan and locals")
+	generateCmd.PersistentFlags().StringVar(&changedFilesFrom, "changed-files-from", "", "Path of a file listing the changed files, one per line relative to the directory project dirs are relative to, for --autoplan-mode=changed. Blank lines and lines starting with # are skipped")
 	generateCmd.PersistentFlags().BoolVar(&autoMerge, "automerge", false, "Enable auto merge. Default is disabled")
 	generateCmd.PersistentFlags().BoolVar(&ignoreParentTerragrunt, "ignore-parent-terragrunt", true, "Ignore parent terragrunt configs (those which don't reference a terraform module). Default is enabled")
 	generateCmd.PersistentFlags().BoolVar(&createParentProject, "create-parent-project", false, "Create a project for the parent terragrunt configs (those which don't reference a terraform module). Default is disabled")
+	generateCmd.PersistentFlags().BoolVar(&includeRootProject, "include-root-project", false, "Create a project for the terragrunt config at the top of a root when it references a terraform module, even when the modules below include it. Default is disabled")
+	generateCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Level of the logs written to stderr, like debug to see why each terragrunt config is a module or a parent config. Default is info")
+	generateCmd.PersistentFlags().BoolVar(&quiet, "quiet", false, "Only log errors, like --log-level=error. The generated config is still written. Default is disabled")
+	generateCmd.PersistentFlags().BoolVar(&trackReadFiles, "track-read-files", false, "Add the files read with read_terragrunt_config() and read_tfvars_file() to when_modified. Paths that are not static are skipped with a warning. Default is disabled")
 	generateCmd.PersistentFlags().BoolVar(&ignoreDependencyBlocks, "ignore-dependency-blocks", false, "When true, dependencies found in `dependency` blocks will be ignored")
 	generateCmd.PersistentFlags().BoolVar(&parallel, "parallel", true, "Enables plans and applys to happen in parallel. Default is enabled")
 	generateCmd.PersistentFlags().BoolVar(&createWorkspace, "create-workspace", false, "Use different workspace for each project. Default is use default workspace")
 	generateCmd.PersistentFlags().BoolVar(&createProjectName, "create-project-name", false, "Add different name for each project. Default is false")
 	generateCmd.PersistentFlags().BoolVar(&preserveWorkflows, "preserve-workflows", true, "Preserves workflows from old output files. Default is true")
 	generateCmd.PersistentFlags().BoolVar(&preserveProjects, "preserve-projects", false, "Preserves projects from old output files to enable incremental builds. Default is false")
+	generateCmd.PersistentFlags().BoolVar(&mergeExistingProjectsByName, "merge-existing-projects-by-name", false, "With --preserve-projects, update the existing project with the same name instead of the one with the same dir, so renamed dirs don't leave their old project behind. Default is false")
 	generateCmd.PersistentFlags().BoolVar(&cascadeDependencies, "cascade-dependencies", true, "When true, dependencies will cascade, meaning that a module will be declared to depend not only on its dependencies, but all dependencies of its dependencies all the way down. Default is true")
-	generateCmd.PersistentFlags().StringVar(&defaultWorkflow, "workflow", "", "Name of the workflow to be customized in the atlantis server. Default is to not set")
+	generateCmd.PersistentFlags().StringVar(&defaultWorkflow, "workflow", "", "Name of the workflow to be customized in the atlantis server. A workflow only defined in the server side repo config also needs --allow-undefined-workflows. Default is to not set")
+	generateCmd.PersistentFlags().StringVar(&workflowPrefix, "workflow-prefix", "", "Prefix added to the workflow names of generated projects and of the workflows from atlantis_workflow_steps. Default is none")
+	generateCmd.PersistentFlags().BoolVar(&dedupeWorkflows, "dedupe-workflows", false, "Register a single workflow for identical atlantis_workflow_steps definitions, shared by all projects using them. Default is one workflow per project")
+	generateCmd.PersistentFlags().StringVar(&workflowSuffix, "workflow-suffix", "", "Suffix added to the workflow names of generated projects and of the workflows from atlantis_workflow_steps. Default is none")
 	generateCmd.PersistentFlags().StringSliceVar(&defaultApplyRequirements, "apply-requirements", []string{}, "Requirements that must be satisfied before `atlantis apply` can be run. Currently the only supported requirements are `approved` and `mergeable`. Can be overridden by locals")
-	generateCmd.PersistentFlags().StringVar(&outputPath, "output", "", "Path of the file where configuration will be generated. Default is not to write to file")
-	generateCmd.PersistentFlags().StringSliceVar(&filterPaths, "filter", []string{}, "Comma-separated paths or glob expressions to the directories you want scope down the config for. Default is all files in root.")
-	generateCmd.PersistentFlags().StringVar(&gitRoot, "root", pwd, "Path to the root directory of the git repo you want to build config for. Default is current dir")
+	generateCmd.PersistentFlags().StringSliceVar(&forceApplyRequirements, "force-apply-requirements", []string{}, "Requirements that must be satisfied before `atlantis apply` can be run, for every project. Unlike --apply-requirements, they can't be overridden by locals. Default is to not set")
+	generateCmd.PersistentFlags().StringSliceVar(&defaultPlanRequirements, "plan-requirements", []string{}, "Requirements that must be satisfied before `atlantis plan` can be run, like `approved` or `mergeable`. Can be overridden by locals. Default is to not set")
+	generateCmd.PersistentFlags().BoolVar(&mergeApplyRequirements, "merge-apply-requirements", false, "Add the `atlantis_apply_requirements` local of a project to the --apply-requirements instead of replacing them. Default is false")
+	generateCmd.PersistentFlags().StringVar(&repoLocksMode, "repo-locks-mode", "", "When Atlantis locks each project: on_plan, on_apply or disabled, output as repo_locks. Can be overridden by locals. Default is to not set")
+	generateCmd.PersistentFlags().BoolVar(&includeSubmodules, "include-submodules", false, "Discover modules inside of git submodules, which have a .git file, below the --root. Default is to skip submodules")
+	generateCmd.PersistentFlags().BoolVar(&detectGitRoot, "detect-git-root", false, "Make project dirs relative to the nearest directory containing .git at or above the --root, so running from a subdirectory of the repo gives the same dirs. An explicit --base-dir takes precedence. Default is false")
+	generateCmd.PersistentFlags().StringVar(&baseDir, "base-dir", "", "Directory that all project dirs are made relative to, independent of --root and --output. Every --root must be inside of it. Default is the common parent directory of all --root")
+	generateCmd.PersistentFlags().StringVar(&outputPath, "output", "", "Path of the file where configuration will be generated, or - to write it to stdout. Nothing is preserved when writing to stdout, unless --preserve-from is set. Default is not to write to file")
+	generateCmd.PersistentFlags().StringVar(&preserveFrom, "preserve-from", "", "Path of the existing config that --preserve-workflows, --preserve-projects and --manage keep parts of. Default is the --output file")
+	generateCmd.PersistentFlags().BoolVar(&checkOrphans, "check-orphans", false, "Warn about projects kept from the existing config whose dir has no terragrunt module or project hcl file anymore. Default is false")
+	generateCmd.PersistentFlags().BoolVar(&pruneOrphans, "prune-orphans", false, "Drop projects kept from the existing config whose dir has no terragrunt module or project hcl file anymore. Default is false")
+	generateCmd.PersistentFlags().StringSliceVar(&updateOnly, "update-only", []string{}, "Comma-separated paths of the directories to generate the projects below of. All other projects are kept as they are in the existing config. Default is to generate all projects")
+	generateCmd.PersistentFlags().StringSliceVar(&filterPaths, "filter", []string{}, "Comma-separated paths or glob expressions to the directories you want scope down the config for. `**` matches any number of directories. Default is all files in root.")
+	generateCmd.PersistentFlags().StringVar(&filterFile, "filter-file", "", "Path of a file with more --filter patterns, one per line. Blank lines and lines starting with # are skipped. Default is to not read one")
+	generateCmd.PersistentFlags().StringArrayVar(&gitRoots, "root", []string{pwd}, "Path to the root directory of the git repo you want to build config for. Can be repeated to merge several roots into one config, with project dirs relative to their common parent directory. Default is current dir")
 	generateCmd.PersistentFlags().StringVar(&defaultTerraformVersion, "terraform-version", "", "Default terraform version to specify for all modules. Can be overriden by locals")
 	generateCmd.PersistentFlags().Int64Var(&numExecutors, "num-executors", 15, "Number of executors used for parallel generation of projects. Default is 15")
 	generateCmd.PersistentFlags().StringSliceVar(&projectHclFiles, "project-hcl-files", []string{}, "Comma-separated names of arbitrary hcl files in the terragrunt hierarchy to create Atlantis projects for. Disables the --filter flag")
 	generateCmd.PersistentFlags().BoolVar(&createHclProjectChilds, "create-hcl-project-childs", false, "Creates Atlantis projects for terragrunt child modules below the directories containing the HCL files defined in --project-hcl-files")
+	generateCmd.PersistentFlags().BoolVar(&keepEmptyHclProjects, "keep-empty-hcl-projects", false, "Keep projects for project hcl files without any terragrunt modules below them. Default is to drop them")
 	generateCmd.PersistentFlags().BoolVar(&createHclProjectExternalChilds, "create-hcl-project-external-childs", true, "Creates Atlantis projects for terragrunt child modules outside the directories containing the HCL files defined in --project-hcl-files")
 	generateCmd.PersistentFlags().BoolVar(&useProjectMarkers, "use-project-markers", false, "Creates Atlantis projects only for project hcl files with locals: atlantis_project = true")
 	generateCmd.PersistentFlags().BoolVar(&executionOrderGroups, "execution-order-groups", false, "Computes execution_order_groups for projects")
+	generateCmd.PersistentFlags().IntVar(&executionOrderStep, "execution-order-step", 1, "Difference between a computed execution_order_group and the group of its dependencies, like 10 to leave room for groups pinned with atlantis_execution_order_group in between. Default is 1")
+	generateCmd.PersistentFlags().StringVar(&atlantisVersion, "atlantis-version", "", "Version of the Atlantis server reading the config, like 0.27.0. Project fields it does not support are dropped with a warning. Default is to emit all fields")
+	generateCmd.PersistentFlags().StringVar(&postProcess, "post-process", "", "Shell command receiving the generated config on stdin and writing the config to output on stdout, like ./add-tags.sh. Its output is checked again before it is written")
 	generateCmd.PersistentFlags().BoolVar(&dependsOn, "depends-on", false, "Computes depends_on for projects. Requires --create-project-name.")
+	generateCmd.PersistentFlags().StringSliceVar(&defaultTags, "default-tags", []string{}, "Comma-separated tags added to every project, before the ones from the `atlantis_tags` local. Default is to not set")
+	generateCmd.PersistentFlags().IntVar(&projectDirLevels, "include-parent-in-project-dir", 0, "Number of directory levels above each module to use as its project dir, so plans run from a parent directory. Modules ending up in the same dir share one project. Applied after --ignore-parent-terragrunt and --create-parent-project decide which configs are modules. Default is 0, the module dir itself")
+	generateCmd.PersistentFlags().IntVar(&groupByDepth, "group-by-depth", 0, "Depth of the project dirs below the root, like 2 for prod/us-east-1. All modules below a dir at that depth share its project, as for terragrunt run-all over subtrees. Modules above it keep their own dir. Default is 0, a project per module")
+	generateCmd.PersistentFlags().StringVar(&stripPrefix, "strip-prefix", "", "Leading dirs to remove from the project dirs, like environments/. A stripped dir must be the same dir relative to the config file, which is checked. Default is none")
+	generateCmd.PersistentFlags().BoolVar(&stripPrefixNames, "strip-prefix-names", false, "Also remove --strip-prefix from the start of the generated project names. Default is disabled")
+	generateCmd.PersistentFlags().BoolVar(&outputProjectsOnly, "output-projects-only", false, "Only output the list of projects, without the projects key and the rest of the config, to splice it into another config. The output is not read as an existing config. Default is disabled")
+	generateCmd.PersistentFlags().BoolVar(&keepGoing, "keep-going", false, "Skip modules whose project can't be generated, like ones with circular includes, with a warning instead of failing. Default is disabled")
+	generateCmd.PersistentFlags().BoolVar(&relativizeExtraDependencies, "relativize-extra-dependencies", false, "Make relative extra_atlantis_dependencies of included configs relative to the included config instead of to the child module. Default is disabled")
+	generateCmd.PersistentFlags().StringVar(&terragruntCompat, "terragrunt-compat", "modern", "Terragrunt behavior profile to generate for: legacy or modern. legacy treats no file as a root config, as children include a parent terragrunt.hcl. modern treats root.hcl and root.hcl.json as root configs that are not modules")
+	generateCmd.PersistentFlags().StringVar(&sortProjectsBy, "sort-projects-by", "dir", "Order of the generated projects: dir, name or execution-order. execution-order sorts by execution_order_group, then dir, and requires --execution-order-groups. Default is dir, or execution-order when --execution-order-groups is set")
+	generateCmd.PersistentFlags().StringVar(&workspaceTemplateText, "workspace-template", "", "Go template for the workspace of each project, with .Dir, .Segments (the parts of .Dir) and .Locals (string locals) available. Takes precedence over --create-workspace. Default is to not set")
+	generateCmd.PersistentFlags().BoolVar(&emitDescriptions, "emit-descriptions", false, "Write the `atlantis_description` local of each project as a comment above it. Default is false")
+	generateCmd.PersistentFlags().StringSliceVar(&allowedOverrides, "allowed-overrides", []string{}, "Comma-separated project keys to output as `allowed_overrides`, like workflow,apply_requirements. Default is to not set")
+	generateCmd.PersistentFlags().BoolVar(&allowCustomWorkflows, "allow-custom-workflows", false, "Output `allow_custom_workflows: true`. Default is false")
+	generateCmd.PersistentFlags().StringSliceVar(&manage, "manage", []string{}, "Comma-separated top level keys to write: version, automerge, parallel, projects, workflows, allowed_overrides, allow_custom_workflows. All other keys of an existing output file are kept untouched. Default is to write the whole file")
+	generateCmd.PersistentFlags().StringVar(&repoConfigHcl, "repo-config-hcl", "", "Path, relative to --base-dir, of an hcl file whose `atlantis_*` locals set repo wide defaults. Explicitly set flags take precedence. Default is to not read one")
+	generateCmd.PersistentFlags().StringVar(&cpuProfilePath, "cpuprofile", "", "Write a CPU profile of the run to this file, for `go tool pprof`. Default is to not profile")
+	generateCmd.PersistentFlags().StringVar(&tracePath, "trace", "", "Write an execution trace of the run to this file, for `go tool trace`. Default is to not trace")
+	generateCmd.PersistentFlags().StringVar(&summaryFormat, "summary", "", "Write a summary of the run, like the number of modules and projects and the time taken, to stderr in this format. Only json is supported. Default is to not write one")
+	generateCmd.PersistentFlags().BoolVar(&failOnWarnings, "fail-on-warnings", false, "Exit with an error after writing the config if any warnings were emitted, like dependencies without a terragrunt config or skipped files. Default is false")
+	generateCmd.PersistentFlags().BoolVar(&followSymlinks, "follow-symlinks", false, "Follow symlinked directories when discovering modules and project hcl files. Symlinks pointing back into a directory being walked are not followed again. Default is false")
+	generateCmd.PersistentFlags().Int64Var(&maxFileSize, "max-file-size", 10*1024*1024, "Config files larger than this many bytes are skipped with a warning instead of parsed. Default is 10MB")
+	generateCmd.PersistentFlags().BoolVar(&ignoreTfParseErrors, "ignore-tf-parse-errors", false, "Warn instead of failing when the Terraform files of a local module can't be parsed, only tracking the module's own files for it. Default is false")
+	generateCmd.PersistentFlags().BoolVar(&resolveRemoteLocalSubmodules, "resolve-remote-local-submodules", false, "Follow local module calls inside remote modules that Terragrunt already vendored into .terragrunt-cache. Default is false")
+	generateCmd.PersistentFlags().BoolVar(&offline, "offline", false, "Fail instead of resolving a module source over the network, and on configs calling Terragrunt functions that go over the network, like get_aws_account_id or run_cmd. Default is false")
+
+	generateCmd.SetGlobalNormalizationFunc(normalizeFlagAliases)
 }
 
 // Runs a set of arguments, returning the output
 func RunWithFlags(filename string, args []string) ([]byte, error) {
+	content, _, err := RunWithFlagsAndDiagnostics(filename, args)
+	return content, err
+}
+
+// A run keeps its flags and diagnostics in package state, so runs through the library API take turns
+var runMutex sync.Mutex
+
+// Runs a set of arguments like RunWithFlags, also returning the diagnostics collected during the run
+func RunWithFlagsAndDiagnostics(filename string, args []string) ([]byte, []Diagnostic, error) {
+	runMutex.Lock()
+	defer runMutex.Unlock()
+
 	rootCmd.SetArgs(args)
 	rootCmd.Execute()
 
-	return os.ReadFile(filename)
+	content, err := os.ReadFile(filename)
+	return content, diagnostics.All(), err
 }
@@ -1,14 +1,27 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io/fs"
 	"math/rand"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 
 	"github.com/ghodss/yaml"
+	"github.com/gruntwork-io/go-commons/errors"
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/config/hclparse"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/hashicorp/go-getter"
+	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
 	"golang.org/x/sync/singleflight"
 )
@@ -22,30 +35,96 @@ func resetForRun() error {
 
 	// reset caches
 	getDependenciesCache = newGetDependenciesCache()
+	moduleSourcesCache = newModuleSourcesCache()
+	acyclicIncludesCache = newAcyclicIncludesCache()
 	requestGroup = singleflight.Group{}
 	// reset flags
 	gitRoot = pwd
+	baseDir = ""
+	// --root appends to its value once it was set, so it needs a fresh value that replaces the default again
+	freshFlags := pflag.NewFlagSet("generate", pflag.ContinueOnError)
+	freshFlags.StringArrayVar(&gitRoots, "root", []string{pwd}, "")
+	generateCmd.PersistentFlags().Lookup("root").Value = freshFlags.Lookup("root").Value
 	autoPlan = false
 	autoMerge = false
 	cascadeDependencies = true
 	ignoreParentTerragrunt = true
+	includeRootProject = false
+	logLevel = "info"
+	quiet = false
+	trackReadFiles = false
 	ignoreDependencyBlocks = false
 	parallel = true
 	createWorkspace = false
 	createProjectName = false
 	preserveWorkflows = true
 	preserveProjects = true
+	autoplanMode = ""
+	validateOutput = false
+	allowUndefinedWorkflows = false
+	detectGitRoot = false
+	includeSubmodules = false
+	executionOrderStep = 1
+	atlantisVersion = ""
+	postProcess = ""
+	traceSlowModules = 0
+	changedFilesFrom = ""
+	mergeExistingProjectsByName = false
 	defaultWorkflow = ""
+	workflowPrefix = ""
+	workflowSuffix = ""
+	dedupeWorkflows = false
 	filterPaths = []string{}
+	filterFile = ""
 	outputPath = ""
+	preserveFrom = ""
+	updateOnly = []string{}
+	checkOrphans = false
+	pruneOrphans = false
 	defaultTerraformVersion = ""
 	defaultApplyRequirements = []string{}
+	mergeApplyRequirements = false
+	forceApplyRequirements = []string{}
+	defaultPlanRequirements = []string{}
+	repoLocksMode = ""
 	projectHclFiles = []string{}
 	createHclProjectChilds = false
 	createHclProjectExternalChilds = true
 	useProjectMarkers = false
 	executionOrderGroups = false
 	dependsOn = false
+	offline = false
+	resolveRemoteLocalSubmodules = false
+	maxFileSize = 10 * 1024 * 1024
+	followSymlinks = false
+	diagnostics = &Diagnostics{}
+	failOnWarnings = false
+	repoConfigHcl = ""
+	manage = []string{}
+	allowedOverrides = []string{}
+	allowCustomWorkflows = false
+	keepEmptyHclProjects = false
+	emitDescriptions = false
+	workspaceTemplateText = ""
+	sortProjectsBy = "dir"
+	terragruntCompat = "modern"
+	projectDirLevels = 0
+	groupByDepth = 0
+	stripPrefix = ""
+	stripPrefixNames = false
+	outputProjectsOnly = false
+	keepGoing = false
+	relativizeExtraDependencies = false
+	defaultTags = []string{}
+	summaryFormat = ""
+	cpuProfilePath = ""
+	tracePath = ""
+	ignoreTfParseErrors = false
+	// flags keep their changed state between runs of the same command, as well as being marked required by --depends-on
+	generateCmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		flag.Changed = false
+		delete(flag.Annotations, cobra.BashCompOneRequiredFlag)
+	})
 
 	return nil
 }
@@ -87,6 +166,49 @@ func runTest(t *testing.T, goldenFile string, args []string) {
 	assert.Equal(t, goldenContents, content)
 }
 
+// Runs a test, asserting the raw output matches a golden file. Unlike runTest this keeps comments and key order.
+// Unless `existing` is nil, it is written to the output file first, like a config left by a previous run.
+func runRawTest(t *testing.T, goldenFile string, existing []byte, args []string) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	randomInt := rand.Int()
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
+	defer os.Remove(filename)
+
+	if existing != nil {
+		if err := os.WriteFile(filename, existing, 0644); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	allArgs := append([]string{
+		"generate",
+		"--output",
+		filename,
+	}, args...)
+
+	content, err := RunWithFlags(filename, allArgs)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	goldenContents, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Error("Failed to read golden file")
+		return
+	}
+
+	if string(content) != string(goldenContents) {
+		t.Errorf("Content did not match golden file.\n\nExpected Content: %s\n\nContent: %s", string(goldenContents), string(content))
+	}
+}
+
 func TestSettingRoot(t *testing.T) {
 	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
 		"--root",
@@ -144,459 +266,2748 @@ func TestNotIgnoringParentTerragrunt(t *testing.T) {
 	})
 }
 
-func TestEnablingAutoplan(t *testing.T) {
-	runTest(t, filepath.Join("golden", "withAutoplan.yaml"), []string{
+func TestTrackingGenerateBlockFiles(t *testing.T) {
+	runTest(t, filepath.Join("golden", "generate_blocks.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "basic_module"),
-		"--autoplan",
+		filepath.Join("..", "test_examples", "generate_blocks"),
+		"--ignore-parent-terragrunt=false",
 	})
 }
 
-func TestSettingWorkflowName(t *testing.T) {
-	runTest(t, filepath.Join("golden", "namedWorkflow.yaml"), []string{
+func TestIncludingParentInProjectDir(t *testing.T) {
+	runTest(t, filepath.Join("golden", "includeParentInProjectDir.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "basic_module"),
-		"--workflow",
-		"someWorkflow",
+		filepath.Join("..", "test_examples", "with_parent"),
+		"--include-parent-in-project-dir=1",
 	})
 }
 
-func TestExtraDeclaredDependencies(t *testing.T) {
-	runTest(t, filepath.Join("golden", "extra_dependencies.yaml"), []string{
+func TestIncludingParentInProjectDirMergesParentProject(t *testing.T) {
+	runTest(t, filepath.Join("golden", "includeParentInProjectDirWithParent.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "extra_dependency"),
+		filepath.Join("..", "test_examples", "with_parent"),
+		"--include-parent-in-project-dir=1",
+		"--ignore-parent-terragrunt=false",
 	})
 }
 
-func TestNonStringErrorOnExtraDeclaredDependencies(t *testing.T) {
-	err := resetForRun()
-	if err != nil {
-		t.Error("Failed to reset default flags")
-		return
-	}
+func TestIncludingParentInProjectDirStopsAtRoot(t *testing.T) {
+	runTest(t, filepath.Join("golden", "includeParentInProjectDir.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "with_parent"),
+		"--include-parent-in-project-dir=5",
+	})
+}
 
-	rootCmd.SetArgs([]string{
-		"generate",
+func TestGroupingByDepth(t *testing.T) {
+	runTest(t, filepath.Join("golden", "groupByDepth.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples_errors", "extra_dependency_error"),
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--group-by-depth=2",
 	})
-	err = rootCmd.Execute()
-	
-	expectedError := "extra_atlantis_dependencies contains non-string value at position 4"
-	if err == nil || err.Error() != expectedError {
-		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+}
+
+func TestProjectDirLiftLevels(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
 		return
 	}
-	return
+	groupByDepth = 2
+	assert.Equal(t, 0, projectDirLiftLevels("."))
+	assert.Equal(t, 0, projectDirLiftLevels("prod"))
+	assert.Equal(t, 0, projectDirLiftLevels("prod/us-east-1"))
+	assert.Equal(t, 2, projectDirLiftLevels("prod/us-east-1/prod/mysql"))
 }
 
-func TestLocalTerraformModuleSource(t *testing.T) {
-	runTest(t, filepath.Join("golden", "local_terraform_module.yaml"), []string{
-		"--root",
-		filepath.Join("..", "test_examples", "local_terraform_module_source"),
-	})
+func TestMergingLiftedProjects(t *testing.T) {
+	existing := AtlantisProject{Dir: "prod", Workflow: "prod", Autoplan: AutoplanConfig{WhenModified: []string{"b/*.hcl", "a/*.hcl"}}}
+	err := mergeLiftedProject(&existing, AtlantisProject{Dir: "prod", Workflow: "prod", Autoplan: AutoplanConfig{WhenModified: []string{"c/*.hcl", "a/*.hcl"}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a/*.hcl", "b/*.hcl", "c/*.hcl"}, existing.Autoplan.WhenModified)
+
+	err = mergeLiftedProject(&existing, AtlantisProject{Dir: "prod", Workflow: "staging"})
+	assert.Error(t, err)
+
+	// A negated entry of one module would also exclude the files of the other
+	err = mergeLiftedProject(&existing, AtlantisProject{Dir: "prod", Workflow: "prod", Autoplan: AutoplanConfig{WhenModified: []string{"d/*.hcl", "!d/generated/**"}}})
+	assert.ErrorContains(t, err, "have negated when_modified entries")
+	assert.Equal(t, []string{"a/*.hcl", "b/*.hcl", "c/*.hcl"}, existing.Autoplan.WhenModified)
 }
 
-func TestLocalTerraformAbsModuleSource(t *testing.T) {
-	runTest(t, filepath.Join("golden", "local_terraform_abs_module.yaml"), []string{
+func TestMultipleRoots(t *testing.T) {
+	runTest(t, filepath.Join("golden", "multipleRoots.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "local_terraform_abs_module_source"),
+		filepath.Join("..", "test_examples", "multiple_roots", "product_a"),
+		"--root",
+		filepath.Join("..", "test_examples", "multiple_roots", "product_b"),
 	})
 }
 
-func TestLocalTfModuleSource(t *testing.T) {
-	runTest(t, filepath.Join("golden", "local_tf_module.yaml"), []string{
+func TestOverlappingRoots(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
 		"--root",
-		filepath.Join("..", "test_examples", "local_tf_module_source"),
+		filepath.Join("..", "test_examples", "multiple_roots"),
+		"--root",
+		filepath.Join("..", "test_examples", "multiple_roots", "product_a"),
 	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "project dir product_a/network is generated from both")
 }
 
-func TestTerragruntDependencies(t *testing.T) {
-	runTest(t, filepath.Join("golden", "terragrunt_dependency.yaml"), []string{
+func TestBaseDir(t *testing.T) {
+	runTest(t, filepath.Join("golden", "baseDir.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "terragrunt_dependency"),
+		filepath.Join("..", "test_examples", "multiple_roots", "product_b"),
+		"--base-dir",
+		filepath.Join("..", "test_examples"),
 	})
 }
 
-func TestIgnoringTerragruntDependencies(t *testing.T) {
-	runTest(t, filepath.Join("golden", "terragrunt_dependency_ignored.yaml"), []string{
+func TestStripPrefix(t *testing.T) {
+	repo := t.TempDir()
+	moduleDir := filepath.Join(repo, "environments", "prod", "app")
+	assert.NoError(t, os.MkdirAll(moduleDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(moduleDir, "terragrunt.hcl"), []byte("terraform {\n  source = \"git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4\"\n}\n"), 0644))
+
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	// The config is in the environments dir, so dirs relative to it don't start with environments/
+	filename := filepath.Join(repo, "environments", "atlantis.yaml")
+	contentBytes, err := RunWithFlags(filename, []string{
+		"generate",
+		"--output",
+		filename,
 		"--root",
-		filepath.Join("..", "test_examples", "terragrunt_dependency"),
-		"--ignore-dependency-blocks",
+		repo,
+		"--create-project-name",
+		"--strip-prefix",
+		"environments/",
+		"--strip-prefix-names",
 	})
-}
+	if err != nil {
+		t.Error(err)
+		return
+	}
 
-func TestCustomWorkflowName(t *testing.T) {
-	runTest(t, filepath.Join("golden", "different_workflow_names.yaml"), []string{
+	content := &AtlantisConfig{}
+	assert.NoError(t, yaml.Unmarshal(contentBytes, content))
+	if assert.Len(t, content.Projects, 1) {
+		assert.Equal(t, "prod/app", content.Projects[0].Dir)
+		assert.Equal(t, "prod_app", content.Projects[0].Name)
+	}
+
+	// Relative to a config in the repo root, prod/app is not the module dir anymore
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--output",
+		filepath.Join(repo, "atlantis.yaml"),
 		"--root",
-		filepath.Join("..", "test_examples", "different_workflow_names"),
+		repo,
+		"--strip-prefix",
+		"environments/",
 	})
+	err = rootCmd.Execute()
+	assert.ErrorContains(t, err, "project dir environments/prod/app stripped by --strip-prefix is prod/app, which is not the same dir")
 }
 
-// This test covers parent Terragrunt files that are not runnable as modules themselves.
-// Sometimes it is possible to have parent files that only are runnable when included
-// into child modules.
-func TestUnparseableParent(t *testing.T) {
-	runTest(t, filepath.Join("golden", "invalid_parent_module.yaml"), []string{
+func TestDetectingGitRoot(t *testing.T) {
+	repo := t.TempDir()
+	moduleDir := filepath.Join(repo, "live", "prod", "app")
+	assert.NoError(t, os.MkdirAll(filepath.Join(repo, ".git"), 0755))
+	assert.NoError(t, os.MkdirAll(moduleDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(moduleDir, "terragrunt.hcl"), []byte("terraform {\n  source = \"git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4\"\n}\n"), 0644))
+
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	filename := filepath.Join(t.TempDir(), "atlantis.yaml")
+	contentBytes, err := RunWithFlags(filename, []string{
+		"generate",
+		"--output",
+		filename,
 		"--root",
-		filepath.Join("..", "test_examples", "invalid_parent_module"),
+		filepath.Join(repo, "live", "prod"),
+		"--detect-git-root",
 	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	content := &AtlantisConfig{}
+	assert.NoError(t, yaml.Unmarshal(contentBytes, content))
+	assert.Len(t, content.Projects, 1)
+	assert.Equal(t, "live/prod/app", content.Projects[0].Dir)
+}
+
+// Git refuses to commit a `.git` file into the fixtures, so the submodule is set up in a temporary dir
+func TestSkippingSubmodules(t *testing.T) {
+	root := t.TempDir()
+	module := []byte("terraform {\n  source = \"git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4\"\n}\n")
+	for _, dir := range []string{"app", filepath.Join("vendored", "module")} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(root, dir, "terragrunt.hcl"), module, 0644))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "vendored", ".git"), []byte("gitdir: ../.git/modules/vendored\n"), 0644))
+
+	for _, testCase := range []struct {
+		flags    []string
+		expected []string
+	}{
+		{[]string{}, []string{"app"}},
+		{[]string{"--include-submodules"}, []string{"app", "vendored/module"}},
+	} {
+		err := resetForRun()
+		if err != nil {
+			t.Error("Failed to reset default flags")
+			return
+		}
+
+		filename := filepath.Join(t.TempDir(), "atlantis.yaml")
+		contentBytes, err := RunWithFlags(filename, append([]string{
+			"generate",
+			"--output",
+			filename,
+			"--root",
+			root,
+		}, testCase.flags...))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		content := &AtlantisConfig{}
+		assert.NoError(t, yaml.Unmarshal(contentBytes, content))
+		dirs := []string{}
+		for _, project := range content.Projects {
+			dirs = append(dirs, project.Dir)
+		}
+		assert.Equal(t, testCase.expected, dirs, "flags %v", testCase.flags)
+	}
 }
 
-func TestWithWorkspaces(t *testing.T) {
-	runTest(t, filepath.Join("golden", "withWorkspace.yaml"), []string{
-		"--root",
-		filepath.Join("..", "test_examples", "basic_module"),
-		"--create-workspace",
-	})
+func TestDetectingGitRootWithoutGit(t *testing.T) {
+	_, err := findGitRoot(filepath.Join(string(filepath.Separator), "nonexistent", "module"))
+	assert.ErrorContains(t, err, "no .git found in")
 }
 
-func TestWithProjectNames(t *testing.T) {
-	runTest(t, filepath.Join("golden", "withProjectName.yaml"), []string{
+func TestRootOutsideOfBaseDir(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
 		"--root",
-		filepath.Join("..", "test_examples", "invalid_parent_module"),
-		"--create-project-name",
+		filepath.Join("..", "test_examples", "multiple_roots"),
+		"--base-dir",
+		filepath.Join("..", "test_examples", "multiple_roots", "product_a"),
 	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "is not inside --base-dir")
 }
 
-func TestMergingLocalDependenciesFromParent(t *testing.T) {
-	runTest(t, filepath.Join("golden", "mergeParentDependencies.yaml"), []string{
+func TestEnablingAutoplan(t *testing.T) {
+	runTest(t, filepath.Join("golden", "withAutoplan.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "parent_with_extra_deps"),
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--autoplan",
 	})
 }
 
-func TestWorkflowFromParentInLocals(t *testing.T) {
-	runTest(t, filepath.Join("golden", "parentDefinedWorkflow.yaml"), []string{
+func TestEnablingAutoplanFromEnv(t *testing.T) {
+	t.Setenv("TGAC_AUTOPLAN", "true")
+	runTest(t, filepath.Join("golden", "withAutoplan.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "parent_with_workflow_local"),
+		filepath.Join("..", "test_examples", "basic_module"),
 	})
 }
 
-func TestChildWorkflowOverridesParentWorkflow(t *testing.T) {
-	runTest(t, filepath.Join("golden", "parentAndChildDefinedWorkflow.yaml"), []string{
+// Flags given on the command line take precedence over the environment
+func TestFlagsOverridingEnv(t *testing.T) {
+	t.Setenv("TGAC_AUTOPLAN", "true")
+	t.Setenv("TGAC_APPLY_REQUIREMENTS", "approved,mergeable")
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "child_and_parent_specify_workflow"),
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--autoplan=false",
+		"--apply-requirements=",
 	})
 }
 
-func TestExtraArguments(t *testing.T) {
-	runTest(t, filepath.Join("golden", "extraArguments.yaml"), []string{
+func TestInvalidEnvValue(t *testing.T) {
+	t.Setenv("TGAC_AUTOPLAN", "sometimes")
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
 		"--root",
-		filepath.Join("..", "test_examples", "extra_arguments"),
+		filepath.Join("..", "test_examples", "basic_module"),
 	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "invalid TGAC_AUTOPLAN")
 }
 
-func TestInfrastructureLive(t *testing.T) {
-	runTest(t, filepath.Join("golden", "infrastructureLive.yaml"), []string{
-		"--root",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
-	})
+func TestEnvVarName(t *testing.T) {
+	assert.Equal(t, "TGAC_AUTOPLAN", envVarName("autoplan"))
+	assert.Equal(t, "TGAC_CREATE_PROJECT_NAME", envVarName("create-project-name"))
 }
 
-func TestModulesWithNoTerraformSourceDefinitions(t *testing.T) {
-	runTest(t, filepath.Join("golden", "no_terraform_blocks.yml"), []string{
+func TestSettingWorkflowName(t *testing.T) {
+	runTest(t, filepath.Join("golden", "namedWorkflow.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "no_terraform_blocks"),
-		"--parallel",
-		"--autoplan",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--allow-undefined-workflows",
+		"--workflow",
+		"someWorkflow",
 	})
 }
 
-func TestInfrastructureMutliAccountsVPCRoute53TGWCascading(t *testing.T) {
-	runTest(t, filepath.Join("golden", "multi_accounts_vpc_route53_tgw.yaml"), []string{
+func TestExtraDeclaredDependencies(t *testing.T) {
+	runTest(t, filepath.Join("golden", "extra_dependencies.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "multi_accounts_vpc_route53_tgw"),
-		"--cascade-dependencies",
+		filepath.Join("..", "test_examples", "extra_dependency"),
 	})
 }
 
-func TestAutoPlan(t *testing.T) {
-	runTest(t, filepath.Join("golden", "autoplan.yaml"), []string{
+func TestNonStringErrorOnExtraDeclaredDependencies(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
 		"--root",
-		filepath.Join("..", "test_examples", "autoplan"),
-		"--autoplan=false",
+		filepath.Join("..", "test_examples_errors", "extra_dependency_error"),
 	})
+	err = rootCmd.Execute()
+
+	expectedError := "extra_atlantis_dependencies contains non-string value at position 4"
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+		return
+	}
+	return
 }
 
-func TestSkippingModules(t *testing.T) {
-	runTest(t, filepath.Join("golden", "skip.yaml"), []string{
+func TestRepoConfigHcl(t *testing.T) {
+	runTest(t, filepath.Join("golden", "repo_config_hcl.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "skip"),
+		filepath.Join("..", "test_examples", "repo_config_hcl"),
+		"--allow-undefined-workflows",
+		"--repo-config-hcl",
+		"repo.hcl",
 	})
 }
 
-func TestTerraformVersionConfig(t *testing.T) {
-	runTest(t, filepath.Join("golden", "terraform_version.yaml"), []string{
+func TestRepoConfigHclOverriddenByFlags(t *testing.T) {
+	runTest(t, filepath.Join("golden", "repo_config_hcl_flags.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "terraform_version"),
-		"--terraform-version", "0.14.9001",
+		filepath.Join("..", "test_examples", "repo_config_hcl"),
+		"--allow-undefined-workflows",
+		"--repo-config-hcl",
+		"repo.hcl",
+		"--workflow",
+		"flag-workflow",
+		"--automerge=false",
 	})
 }
 
-func TestPreservingOldWorkflows(t *testing.T) {
-	err := resetForRun()
-	if err != nil {
+func TestJsonSummary(t *testing.T) {
+	if err := resetForRun(); err != nil {
 		t.Error("Failed to reset default flags")
 		return
 	}
+	stderr := &bytes.Buffer{}
+	rootCmd.SetErr(stderr)
+	defer rootCmd.SetErr(nil)
 
-	randomInt := rand.Int()
-	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
-	defer os.Remove(filename)
-
-	// Create an existing file to simulate an existing atlantis.yaml file
-	contents := []byte(`workflows:
-  terragrunt:
-    apply:
-      steps:
-      - run: terragrunt apply -no-color $PLANFILE
-    plan:
-      steps:
-      - run: terragrunt plan -no-color -out $PLANFILE
-`)
-	os.WriteFile(filename, contents, 0644)
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--summary=json",
+	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
 
-	content, err := RunWithFlags(filename, []string{
+	summary := Summary{}
+	if err := json.Unmarshal(stderr.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(4), summary.ModulesDiscovered)
+	assert.Equal(t, 4, summary.ProjectsEmitted)
+	assert.Equal(t, int64(4), summary.DependenciesResolved)
+	assert.Positive(t, summary.DependencyCacheHits)
+	assert.Equal(t, 0, summary.Warnings)
+}
+
+func TestQuietOnlyLogsErrors(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	logs := &bytes.Buffer{}
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+	stdout := &bytes.Buffer{}
+	rootCmd.SetOut(stdout)
+	defer rootCmd.SetOut(nil)
+
+	// The fixture has a dependency without a terragrunt config, which is warned about
+	rootCmd.SetArgs([]string{
 		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "hcl_json"),
+		"--allow-undefined-workflows",
 		"--output",
-		filename,
+		"-",
+		"--quiet",
+	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, logs.String())
+	assert.Len(t, diagnostics.All(), 1)
+	config, err := ParseAtlantisConfig(stdout.Bytes())
+	assert.NoError(t, err)
+	assert.NotEmpty(t, config.Projects)
+}
+
+func TestWritingToStdout(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	stdout := &bytes.Buffer{}
+	rootCmd.SetOut(stdout)
+	defer rootCmd.SetOut(nil)
+
+	rootCmd.SetArgs([]string{
+		"generate",
 		"--root",
 		filepath.Join("..", "test_examples", "basic_module"),
+		"--output",
+		"-",
 	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	content := &AtlantisConfig{}
+	if err := yaml.Unmarshal(stdout.Bytes(), content); err != nil {
+		t.Fatal(err)
+	}
+	goldenContentsBytes, err := os.ReadFile(filepath.Join("golden", "basic.yaml"))
 	if err != nil {
-		t.Error("Failed to read file")
+		t.Fatal(err)
+	}
+	goldenContents := &AtlantisConfig{}
+	if err := yaml.Unmarshal(goldenContentsBytes, goldenContents); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, goldenContents, content)
+
+	// No file named after the output is written or read
+	assert.NoFileExists(t, "-")
+}
+
+func TestUnknownSummaryFormat(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
 		return
 	}
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--summary=yaml",
+	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "unknown --summary value")
+}
 
-	goldenContents, err := os.ReadFile(filepath.Join("golden", "oldWorkflowsPreserved.yaml"))
-	if err != nil {
-		t.Error("Failed to read golden file")
+func TestProfiling(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
 		return
 	}
+	profileDir := t.TempDir()
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--cpuprofile",
+		filepath.Join(profileDir, "cpu.pprof"),
+		"--trace",
+		filepath.Join(profileDir, "trace.out"),
+	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
 
-	if string(content) != string(goldenContents) {
-		t.Errorf("Content did not match golden file.\n\nExpected Content: %s\n\nContent: %s", string(goldenContents), string(content))
+	for _, name := range []string{"cpu.pprof", "trace.out"} {
+		info, err := os.Stat(filepath.Join(profileDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Positive(t, info.Size(), name)
 	}
 }
 
-func TestPreservingOldProjects(t *testing.T) {
-	err := resetForRun()
+func TestFailOnWarnings(t *testing.T) {
+	for _, failOnWarningsFlag := range []bool{false, true} {
+		err := resetForRun()
+		if err != nil {
+			t.Error("Failed to reset default flags")
+			return
+		}
+
+		filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", rand.Int()))
+		defer os.Remove(filename)
+
+		rootCmd.SetArgs([]string{
+			"generate",
+			"--output",
+			filename,
+			"--root",
+			filepath.Join("..", "test_examples", "hcl_json"),
+			"--allow-undefined-workflows",
+			fmt.Sprintf("--fail-on-warnings=%t", failOnWarningsFlag),
+		})
+		err = rootCmd.Execute()
+
+		if failOnWarningsFlag {
+			expectedError := "1 warning(s) were emitted while generating the config and --fail-on-warnings is set"
+			if err == nil || err.Error() != expectedError {
+				t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+			}
+		} else if err != nil {
+			t.Errorf("Expected no error without --fail-on-warnings, got '%v'", err)
+		}
+	}
+}
+
+func TestLocalTerraformModuleSource(t *testing.T) {
+	runTest(t, filepath.Join("golden", "local_terraform_module.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "local_terraform_module_source"),
+	})
+}
+
+func TestLocalTerraformAbsModuleSource(t *testing.T) {
+	runTest(t, filepath.Join("golden", "local_terraform_abs_module.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "local_terraform_abs_module_source"),
+	})
+}
+
+func TestLocalTofuOnlyModuleSource(t *testing.T) {
+	runTest(t, filepath.Join("golden", "local_tofu_module.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "local_tofu_module_source"),
+	})
+}
+
+func TestOpenTofuProviderSyntax(t *testing.T) {
+	runTest(t, filepath.Join("golden", "opentofu_provider_syntax.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "opentofu_provider_syntax"),
+	})
+}
+
+func TestOpenTofuProviderSyntaxSkipsTfconfig(t *testing.T) {
+	fixture := filepath.Join("..", "test_examples", "opentofu_provider_syntax")
+
+	rootModuleFiles, err := findModuleFiles(filepath.Join(fixture, "root-module"))
+	assert.NoError(t, err)
+	assert.True(t, hasOpenTofuProviderSyntax(rootModuleFiles))
+
+	networkModuleFiles, err := findModuleFiles(filepath.Join(fixture, "network-module"))
+	assert.NoError(t, err)
+	assert.False(t, hasOpenTofuProviderSyntax(networkModuleFiles))
+
+	sources, err := loadModuleCallSources(filepath.Join(fixture, "root-module"), true, []string{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"../network-module"}, sources)
+}
+
+func TestLocalTerraformFileSchemeModuleSource(t *testing.T) {
+	runTest(t, filepath.Join("golden", "local_terraform_file_module.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "local_terraform_file_module_source"),
+	})
+}
+
+func TestAbsoluteFileSchemeModuleSource(t *testing.T) {
+	root := t.TempDir()
+	sharedDir := filepath.Join(root, "shared")
+	moduleDir := filepath.Join(root, "module")
+	assert.NoError(t, os.MkdirAll(sharedDir, 0755))
+	assert.NoError(t, os.MkdirAll(moduleDir, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(sharedDir, "main.tf"), []byte("resource \"some_resource\" \"some_name\" {}\n"), 0644))
+	assert.NoError(t, os.WriteFile(
+		filepath.Join(moduleDir, "main.tf"),
+		[]byte(fmt.Sprintf("module \"shared\" {\n  source = \"file://%s\"\n}\n", filepath.ToSlash(sharedDir))),
+		0644,
+	))
+
+	sources, err := parseTerraformLocalModuleSource(moduleDir)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.ToSlash(filepath.Join(sharedDir, "*.tf*"))}, sources)
+
+	// The absolute glob must still be relativized to the project directory in the generated config
+	assert.NoError(t, os.WriteFile(filepath.Join(moduleDir, "terragrunt.hcl"), []byte("terraform {\n  source = \".\"\n}\n"), 0644))
+	err = resetForRun()
 	if err != nil {
 		t.Error("Failed to reset default flags")
 		return
 	}
 
-	randomInt := rand.Int()
-	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
-	defer os.Remove(filename)
-
-	// Create an existing file to simulate an existing atlantis.yaml file
-	contents := []byte(`projects:
-- autoplan:
-    enabled: false
-    when_modified:
-    - '*.hcl'
-    - '*.tf*'
-  dir: someDir
-  name: projectFromPreviousRun
-`)
-	os.WriteFile(filename, contents, 0644)
-
-	content, err := RunWithFlags(filename, []string{
+	filename := filepath.Join(root, "atlantis.yaml")
+	contentBytes, err := RunWithFlags(filename, []string{
 		"generate",
-		"--preserve-projects",
 		"--output",
 		filename,
 		"--root",
-		filepath.Join("..", "test_examples", "basic_module"),
+		root,
 	})
 	if err != nil {
-		t.Error("Failed to read file")
+		t.Error(err)
 		return
 	}
 
-	goldenContents, err := os.ReadFile(filepath.Join("golden", "oldProjectsPreserved.yaml"))
-	if err != nil {
-		t.Error("Failed to read golden file")
-		return
+	content := &AtlantisConfig{}
+	assert.NoError(t, yaml.Unmarshal(contentBytes, content))
+	assert.Len(t, content.Projects, 1)
+	assert.Equal(t, "module", content.Projects[0].Dir)
+	assert.Contains(t, content.Projects[0].Autoplan.WhenModified, "../shared/*.tf*")
+	for _, glob := range content.Projects[0].Autoplan.WhenModified {
+		assert.False(t, filepath.IsAbs(glob), "when_modified should not contain absolute path %s", glob)
 	}
+}
 
-	if string(content) != string(goldenContents) {
-		t.Errorf("Content did not match golden file.\n\nExpected Content: %s\n\nContent: %s", string(goldenContents), string(content))
+func TestLocalTerraformModuleSourcePath(t *testing.T) {
+	for raw, expected := range map[string]string{
+		"./module":                  "./module",
+		"../module":                 "../module",
+		"file://../module":          "../module",
+		"file:///abs/module":        "/abs/module",
+		"file://module":             "module",
+		"registry.terraform.io/x/y": "",
+		"registry.terraform.io/x/y/z//modules/sub":       "",
+		"git::https://example.com/repo.git//modules/sub": "",
+		"file://":                     "",
+		"../module?ref=v1.2.3":        "../module",
+		"file://../module?ref=v1.2.3": "../module",
+		"git::https://example.com/repo.git//mod?ref=tag": "",
+		"registry.terraform.io/x/y/z?version=1.0.0":      "",
+	} {
+		path, ok := localTerraformModuleSourcePath(raw)
+		assert.Equal(t, expected != "", ok, raw)
+		assert.Equal(t, expected, path, raw)
 	}
 }
 
-func TestEnablingAutomerge(t *testing.T) {
-	runTest(t, filepath.Join("golden", "withAutomerge.yaml"), []string{
+func TestLocalTerraformModuleSourceWindowsSeparators(t *testing.T) {
+	runTest(t, filepath.Join("golden", "local_terraform_windows_module.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "basic_module"),
-		"--automerge",
+		filepath.Join("..", "test_examples", "local_terraform_windows_module_source"),
 	})
 }
 
-func TestChainedDependencies(t *testing.T) {
-	runTest(t, filepath.Join("golden", "chained_dependency.yaml"), []string{
+func TestLocalTfModuleSource(t *testing.T) {
+	runTest(t, filepath.Join("golden", "local_tf_module.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "chained_dependencies"),
-		"--cascade-dependencies",
+		filepath.Join("..", "test_examples", "local_tf_module_source"),
 	})
 }
 
-func TestChainedDependenciesHiddenBehindFlag(t *testing.T) {
-	runTest(t, filepath.Join("golden", "chained_dependency_no_flag.yaml"), []string{
+// Module calls in `.tf.json` files are followed, and their files are matched by the `*.tf*` glob
+func TestLocalTfJsonModuleSource(t *testing.T) {
+	runTest(t, filepath.Join("golden", "local_tf_json_module.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "chained_dependencies"),
-		"--cascade-dependencies=false",
+		filepath.Join("..", "test_examples", "local_tf_json_module_source"),
 	})
 }
 
-func TestApplyRequirementsLocals(t *testing.T) {
-	runTest(t, filepath.Join("golden", "apply_overrides.yaml"), []string{
+// Remote sources with a `?ref=` stay remote, while local sources have their query string removed
+func TestModuleSourceQueryStrings(t *testing.T) {
+	runTest(t, filepath.Join("golden", "module_source_query_strings.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "apply_requirements_overrides"),
+		filepath.Join("..", "test_examples", "module_source_query_strings"),
 	})
 }
 
-func TestApplyRequirementsFlag(t *testing.T) {
-	runTest(t, filepath.Join("golden", "apply_overrides_flag.yaml"), []string{
+// A source inherited from an included config is resolved and followed for every child including it
+func TestInheritedModuleSource(t *testing.T) {
+	runTest(t, filepath.Join("golden", "inherited_module_source.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "inherited_module_source"),
+	})
+}
+
+// Files read with `file()` and `templatefile()` from static paths are tracked, dynamic paths are skipped with a warning
+func TestTemplatefileReferences(t *testing.T) {
+	runTest(t, filepath.Join("golden", "templatefile_references.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "templatefile_references"),
+	})
+
+	warnings := diagnostics.All()
+	if assert.Len(t, warnings, 1) {
+		assert.Contains(t, warnings[0].Message, "not tracking the file read by file() at line 17")
+	}
+}
+
+// Files read with `read_terragrunt_config()` and `read_tfvars_file()` from static paths are tracked, dynamic paths are
+// skipped with a warning
+func TestTrackingReadFiles(t *testing.T) {
+	runTest(t, filepath.Join("golden", "read_config_files.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "read_config_files"),
+		"--track-read-files",
+	})
+
+	warnings := diagnostics.All()
+	if assert.Len(t, warnings, 1) {
+		assert.Contains(t, warnings[0].Message, "not tracking the file read by read_terragrunt_config() at line 7")
+	}
+}
+
+func TestTerragruntDependencies(t *testing.T) {
+	runTest(t, filepath.Join("golden", "terragrunt_dependency.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt_dependency"),
+	})
+}
+
+func TestIgnoringTerragruntDependencies(t *testing.T) {
+	runTest(t, filepath.Join("golden", "terragrunt_dependency_ignored.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt_dependency"),
+		"--ignore-dependency-blocks",
+	})
+}
+
+func TestCustomWorkflowName(t *testing.T) {
+	runTest(t, filepath.Join("golden", "different_workflow_names.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "different_workflow_names"),
+		"--allow-undefined-workflows",
+	})
+}
+
+// This test covers parent Terragrunt files that are not runnable as modules themselves.
+// Sometimes it is possible to have parent files that only are runnable when included
+// into child modules.
+func TestUnparseableParent(t *testing.T) {
+	runTest(t, filepath.Join("golden", "invalid_parent_module.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "invalid_parent_module"),
+	})
+}
+
+func TestAbstractParentWithInputs(t *testing.T) {
+	runTest(t, filepath.Join("golden", "abstract_parent_with_inputs.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "abstract_parent_with_inputs"),
+	})
+}
+
+func TestRunnableParent(t *testing.T) {
+	runTest(t, filepath.Join("golden", "runnable_parent.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "runnable_parent"),
+	})
+}
+
+// A config without include blocks and a terraform source, but with terraform files next to it, is a module. The files
+// written by its generate blocks are matched by `*.tf*`.
+func TestGenerateOnlyModule(t *testing.T) {
+	runTest(t, filepath.Join("golden", "generate_only_module.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "generate_only_module"),
+	})
+}
+
+func TestWithWorkspaces(t *testing.T) {
+	runTest(t, filepath.Join("golden", "withWorkspace.yaml"), []string{
 		"--root",
 		filepath.Join("..", "test_examples", "basic_module"),
-		"--apply-requirements=approved,mergeable",
+		"--create-workspace",
 	})
 }
 
-func TestFilterFlagWithInfraLiveProd(t *testing.T) {
-	runTest(t, filepath.Join("golden", "filterInfraLiveProd.yaml"), []string{
+func TestWithProjectNames(t *testing.T) {
+	runTest(t, filepath.Join("golden", "withProjectName.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
-		"--filter",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "prod"),
+		filepath.Join("..", "test_examples", "invalid_parent_module"),
+		"--create-project-name",
 	})
 }
 
-func TestFilterFlagWithInfraLiveNonProd(t *testing.T) {
-	runTest(t, filepath.Join("golden", "filterInfraLiveNonProd.yaml"), []string{
+func TestMergingLocalDependenciesFromParent(t *testing.T) {
+	runTest(t, filepath.Join("golden", "mergeParentDependencies.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
-		"--filter",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "non-prod"),
+		filepath.Join("..", "test_examples", "parent_with_extra_deps"),
 	})
 }
 
-func TestFilterFlagWithInfraLiveProdAndNonProd(t *testing.T) {
-	runTest(t, filepath.Join("golden", "filterInfraLiveProdAndNonProd.yaml"), []string{
+func TestRelativizingExtraDependenciesFromParent(t *testing.T) {
+	runTest(t, filepath.Join("golden", "parent_relative_extra_dependencies.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
-		"--filter",
-		strings.Join(
-			[]string{
-				filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "non-prod"),
-				filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "prod"),
-			},
-			",",
-		),
+		filepath.Join("..", "test_examples", "parent_relative_extra_dependencies"),
+		"--relativize-extra-dependencies",
+	})
+}
+
+func TestNegatedExtraDependencies(t *testing.T) {
+	runTest(t, filepath.Join("golden", "negated_extra_dependencies.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "negated_extra_dependencies"),
+	})
+}
+
+func TestDedupingLocalDependenciesFromParent(t *testing.T) {
+	runTest(t, filepath.Join("golden", "duplicate_extra_dependencies.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "duplicate_extra_dependencies"),
+	})
+}
+
+func TestMergingResolvedLocalsDedupesExtraDependencies(t *testing.T) {
+	parent := ResolvedLocals{ExtraAtlantisDependencies: []string{"shared.yaml", "common.json"}}
+	child := ResolvedLocals{ExtraAtlantisDependencies: []string{"common.json", "child_only.yaml", "child_only.yaml", "shared.yaml"}}
+
+	merged := mergeResolvedLocals(parent, child)
+	assert.Equal(t, []string{"shared.yaml", "common.json", "child_only.yaml"}, merged.ExtraAtlantisDependencies)
+}
+
+func TestWorkflowFromParentInLocals(t *testing.T) {
+	runTest(t, filepath.Join("golden", "parentDefinedWorkflow.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "parent_with_workflow_local"),
+		"--allow-undefined-workflows",
+	})
+}
+
+func TestChildWorkflowOverridesParentWorkflow(t *testing.T) {
+	runTest(t, filepath.Join("golden", "parentAndChildDefinedWorkflow.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "child_and_parent_specify_workflow"),
+		"--allow-undefined-workflows",
+	})
+}
+
+func TestExtraArguments(t *testing.T) {
+	runTest(t, filepath.Join("golden", "extraArguments.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "extra_arguments"),
+	})
+}
+
+func TestInfrastructureLive(t *testing.T) {
+	runTest(t, filepath.Join("golden", "infrastructureLive.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+	})
+}
+
+func TestModulesWithNoTerraformSourceDefinitions(t *testing.T) {
+	runTest(t, filepath.Join("golden", "no_terraform_blocks.yml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "no_terraform_blocks"),
+		"--parallel",
+		"--autoplan",
+	})
+}
+
+func TestInfrastructureMutliAccountsVPCRoute53TGWCascading(t *testing.T) {
+	runTest(t, filepath.Join("golden", "multi_accounts_vpc_route53_tgw.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "multi_accounts_vpc_route53_tgw"),
+		"--cascade-dependencies",
+	})
+}
+
+func TestAutoPlan(t *testing.T) {
+	runTest(t, filepath.Join("golden", "autoplan.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "autoplan"),
+		"--autoplan=false",
+	})
+}
+
+func TestSkippingModules(t *testing.T) {
+	runTest(t, filepath.Join("golden", "skip.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "skip"),
+	})
+}
+
+func TestSkippingModulesIndependentOfTerragruntSkip(t *testing.T) {
+	runTest(t, filepath.Join("golden", "skipIndependentOfTerragrunt.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "skip_independent_of_terragrunt"),
+	})
+}
+
+func TestProjectTags(t *testing.T) {
+	runTest(t, filepath.Join("golden", "tags.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "tags"),
+	})
+}
+
+func TestDefaultTags(t *testing.T) {
+	runTest(t, filepath.Join("golden", "defaultTags.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "tags"),
+		"--default-tags=managed",
+	})
+}
+
+func TestTerraformVersionConfig(t *testing.T) {
+	runTest(t, filepath.Join("golden", "terraform_version.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terraform_version"),
+		"--terraform-version", "0.14.9001",
+	})
+}
+
+func TestRepoLocksLocal(t *testing.T) {
+	runTest(t, filepath.Join("golden", "repo_locks.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "repo_locks"),
+	})
+}
+
+func TestRepoLocksModeOnPlan(t *testing.T) {
+	runTest(t, filepath.Join("golden", "repo_locks_on_plan.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "repo_locks"),
+		"--repo-locks-mode=on_plan",
+	})
+}
+
+func TestRepoLocksModeOnApply(t *testing.T) {
+	runTest(t, filepath.Join("golden", "repo_locks_on_apply.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "repo_locks"),
+		"--repo-locks-mode=on_apply",
+	})
+}
+
+func TestRepoLocksModeDisabled(t *testing.T) {
+	runTest(t, filepath.Join("golden", "repo_locks_disabled.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "repo_locks"),
+		"--repo-locks-mode=disabled",
+	})
+}
+
+func TestInvalidRepoLocksMode(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "repo_locks"),
+		"--repo-locks-mode=always",
+	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, `unknown --repo-locks-mode value "always"`)
+}
+
+func TestAtlantisVersionOmitsUnsupportedFields(t *testing.T) {
+	for version, hasRepoLocks := range map[string]bool{
+		"0.26.0":  false,
+		"v0.27.0": true,
+		"":        true,
+	} {
+		err := resetForRun()
+		if err != nil {
+			t.Error("Failed to reset default flags")
+			return
+		}
+
+		filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", rand.Int()))
+		contentBytes, err := RunWithFlags(filename, []string{
+			"generate",
+			"--output",
+			filename,
+			"--root",
+			filepath.Join("..", "test_examples", "repo_locks"),
+			"--repo-locks-mode=on_apply",
+			"--atlantis-version",
+			version,
+		})
+		os.Remove(filename)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		assert.Equal(t, hasRepoLocks, strings.Contains(string(contentBytes), "repo_locks"), "atlantis version %q", version)
+	}
+}
+
+func TestInvalidAtlantisVersion(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "repo_locks"),
+		"--atlantis-version=latest",
+	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, `invalid --atlantis-version "latest"`)
+}
+
+func TestNoOpPostProcessKeepsConfig(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--post-process",
+		"cat",
+	})
+}
+
+func TestPostProcessChangesConfig(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", rand.Int()))
+	defer os.Remove(filename)
+	contentBytes, err := RunWithFlags(filename, []string{
+		"generate",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--post-process",
+		"sed 's/automerge: false/automerge: true/'",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	content := &AtlantisConfig{}
+	assert.NoError(t, yaml.Unmarshal(contentBytes, content))
+	assert.True(t, content.AutoMerge)
+	assert.Len(t, content.Projects, 1)
+}
+
+func TestPostProcessOutputIsChecked(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--output",
+		"-",
+		"--post-process",
+		"sed 's/dir: .*/&\\n  workflow: missing/'",
+	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "projects use workflows that are not defined in workflows: missing")
+}
+
+func TestIncludeRootProject(t *testing.T) {
+	root := t.TempDir()
+	childDir := filepath.Join(root, "child")
+	assert.NoError(t, os.MkdirAll(childDir, 0755))
+	assert.NoError(t, os.WriteFile(
+		filepath.Join(root, "terragrunt.hcl"),
+		[]byte("terraform {\n  source = \"git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4\"\n}\n"),
+		0644,
+	))
+	assert.NoError(t, os.WriteFile(filepath.Join(childDir, "terragrunt.hcl"), []byte("include {\n  path = find_in_parent_folders()\n}\n"), 0644))
+
+	for _, includeRoot := range []bool{false, true} {
+		err := resetForRun()
+		if err != nil {
+			t.Error("Failed to reset default flags")
+			return
+		}
+
+		filename := filepath.Join(root, "atlantis.yaml")
+		contentBytes, err := RunWithFlags(filename, []string{
+			"generate",
+			"--output",
+			filename,
+			"--root",
+			root,
+			// One executor plans the child, which includes the root config, before the root config
+			"--num-executors=1",
+			fmt.Sprintf("--include-root-project=%t", includeRoot),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		content := &AtlantisConfig{}
+		assert.NoError(t, yaml.Unmarshal(contentBytes, content))
+		dirs := []string{}
+		for _, project := range content.Projects {
+			dirs = append(dirs, project.Dir)
+		}
+		if includeRoot {
+			assert.Equal(t, []string{".", "child"}, dirs)
+		} else {
+			assert.Equal(t, []string{"child"}, dirs)
+		}
+	}
+}
+
+func TestOutputProjectsOnly(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", rand.Int()))
+	defer os.Remove(filename)
+	contentBytes, err := RunWithFlags(filename, []string{
+		"generate",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--output-projects-only",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	projects := []AtlantisProject{}
+	assert.NoError(t, yaml.Unmarshal(contentBytes, &projects))
+	if assert.Len(t, projects, 1) {
+		assert.Equal(t, ".", projects[0].Dir)
+		assert.Equal(t, []string{"*.hcl", "*.tf*"}, projects[0].Autoplan.WhenModified)
+	}
+	assert.NotContains(t, string(contentBytes), "version:")
+	assert.NotContains(t, string(contentBytes), "projects:")
+}
+
+func TestPreservingOldWorkflows(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	randomInt := rand.Int()
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
+	defer os.Remove(filename)
+
+	// Create an existing file to simulate an existing atlantis.yaml file
+	contents := []byte(`workflows:
+  terragrunt:
+    apply:
+      steps:
+      - run: terragrunt apply -no-color $PLANFILE
+    plan:
+      steps:
+      - run: terragrunt plan -no-color -out $PLANFILE
+`)
+	os.WriteFile(filename, contents, 0644)
+
+	content, err := RunWithFlags(filename, []string{
+		"generate",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+	})
+	if err != nil {
+		t.Error("Failed to read file")
+		return
+	}
+
+	goldenContents, err := os.ReadFile(filepath.Join("golden", "oldWorkflowsPreserved.yaml"))
+	if err != nil {
+		t.Error("Failed to read golden file")
+		return
+	}
+
+	if string(content) != string(goldenContents) {
+		t.Errorf("Content did not match golden file.\n\nExpected Content: %s\n\nContent: %s", string(goldenContents), string(content))
+	}
+}
+
+func TestPreservingOldProjects(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	randomInt := rand.Int()
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
+	defer os.Remove(filename)
+
+	// Create an existing file to simulate an existing atlantis.yaml file
+	contents := []byte(`projects:
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+    - '*.tf*'
+  dir: someDir
+  name: projectFromPreviousRun
+`)
+	os.WriteFile(filename, contents, 0644)
+
+	content, err := RunWithFlags(filename, []string{
+		"generate",
+		"--preserve-projects",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+	})
+	if err != nil {
+		t.Error("Failed to read file")
+		return
+	}
+
+	goldenContents, err := os.ReadFile(filepath.Join("golden", "oldProjectsPreserved.yaml"))
+	if err != nil {
+		t.Error("Failed to read golden file")
+		return
+	}
+
+	if string(content) != string(goldenContents) {
+		t.Errorf("Content did not match golden file.\n\nExpected Content: %s\n\nContent: %s", string(goldenContents), string(content))
+	}
+}
+
+func TestMergingExistingProjectsByName(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "renamed_app"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "renamed_app", "terragrunt.hcl"), []byte(`locals {
+  atlantis_project_name = "app"
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+`), 0644))
+
+	// The module was at app before, and another project already used its new dir
+	filename := filepath.Join(root, "atlantis.yaml")
+	assert.NoError(t, os.WriteFile(filename, []byte(`projects:
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+    - '*.tf*'
+  dir: app
+  name: app
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+    - '*.tf*'
+  dir: renamed_app
+  name: replaced_app
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+    - '*.tf*'
+  dir: other
+  name: other
+`), 0644))
+
+	for _, mergeByName := range []bool{false, true} {
+		err := resetForRun()
+		if err != nil {
+			t.Error("Failed to reset default flags")
+			return
+		}
+
+		output := filepath.Join(root, "generated.yaml")
+		contentBytes, err := RunWithFlags(output, []string{
+			"generate",
+			"--preserve-projects",
+			fmt.Sprintf("--merge-existing-projects-by-name=%t", mergeByName),
+			"--output",
+			output,
+			"--preserve-from",
+			filename,
+			"--root",
+			root,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		content := &AtlantisConfig{}
+		assert.NoError(t, yaml.Unmarshal(contentBytes, content))
+		projects := []string{}
+		for _, project := range content.Projects {
+			projects = append(projects, project.Name+" in "+project.Dir)
+		}
+		if mergeByName {
+			assert.Equal(t, []string{"other in other", "app in renamed_app"}, projects)
+		} else {
+			// Matched by dir, the old project of the module is left behind
+			assert.Equal(t, []string{"app in app", "other in other", "app in renamed_app"}, projects)
+		}
+	}
+}
+
+func TestMergingExistingProjectsByNameRequiresPreserving(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--preserve-projects=false",
+		"--merge-existing-projects-by-name",
+	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "--merge-existing-projects-by-name requires --preserve-projects")
+}
+
+func TestPreservingFromAnotherFile(t *testing.T) {
+	existing := filepath.Join("test_artifacts", fmt.Sprintf("%d-existing.yaml", rand.Int()))
+	defer os.Remove(existing)
+
+	// The existing config lives somewhere else than the new output file
+	contents := []byte(`projects:
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+    - '*.tf*'
+  dir: someDir
+  name: projectFromPreviousRun
+`)
+	if err := os.WriteFile(existing, contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	runRawTest(t, filepath.Join("golden", "oldProjectsPreserved.yaml"), nil, []string{
+		"--preserve-projects",
+		"--preserve-from",
+		existing,
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+	})
+
+	// The existing config is only read
+	existingContents, err := os.ReadFile(existing)
+	assert.NoError(t, err)
+	assert.Equal(t, string(contents), string(existingContents))
+}
+
+func TestUpdatingOnlyASubtree(t *testing.T) {
+	// The non-prod projects differ from what would be generated for them, while the prod ones are outdated
+	contents := []byte(`projects:
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+  dir: non-prod/us-east-1/qa/mysql
+  name: qa-mysql
+  terraform_version: 0.14.0
+- autoplan:
+    enabled: true
+    when_modified:
+    - '*.hcl'
+    - '*.tf*'
+  dir: non-prod/us-east-1/stage/mysql
+  name: stage-mysql
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+  dir: prod/us-east-1/prod/mysql
+  name: prod-mysql
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+    - '*.tf*'
+  dir: prod/us-east-1/prod/removed
+`)
+	runRawTest(t, filepath.Join("golden", "updateOnlyInfraLiveProd.yaml"), contents, []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--update-only",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "prod"),
+	})
+}
+
+func TestUpdatingOnlyASubtreeWithoutExistingConfig(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", rand.Int()))
+	defer os.Remove(filename)
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--update-only",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "prod"),
+	})
+	err = rootCmd.Execute()
+	assert.ErrorContains(t, err, "--update-only needs an existing config")
+}
+
+func TestRoundTrippingAtlantisConfig(t *testing.T) {
+	// Keys are sorted, as they are written
+	contents := `automerge: false
+delete_source_branch_on_merge: true
+parallel_apply: true
+parallel_plan: true
+projects:
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+    - '*.tf*'
+  branch: /main/
+  dir: someDir
+  name: someProject
+  plan_requirements:
+  - approved
+- autoplan:
+    enabled: true
+    when_modified:
+    - '*.hcl'
+  dir: otherDir
+version: 3
+`
+
+	config, err := ParseAtlantisConfig([]byte(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 3, config.Version)
+	assert.Equal(t, "someProject", config.Projects[0].Name)
+	assert.Equal(t, []string{"*.hcl"}, config.Projects[1].Autoplan.WhenModified)
+
+	bytes, err := MarshalAtlantisConfig(config)
+	assert.NoError(t, err)
+	assert.Equal(t, contents, string(bytes))
+
+	// Changes to known fields are written next to the kept unknown ones
+	config.Projects[0].Name = "renamedProject"
+	bytes, err = MarshalAtlantisConfig(config)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Replace(contents, "name: someProject", "name: renamedProject", 1), string(bytes))
+}
+
+func TestMarshalingAtlantisConfigWithoutUnknownFields(t *testing.T) {
+	config := &AtlantisConfig{
+		Version: 3,
+		Projects: []AtlantisProject{
+			{Dir: "someDir", Autoplan: AutoplanConfig{Enabled: true, WhenModified: []string{"*.hcl"}}},
+		},
+	}
+
+	bytes, err := MarshalAtlantisConfig(config)
+	assert.NoError(t, err)
+	expected, err := yaml.Marshal(config)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), string(bytes))
+}
+
+func TestOrphanedProjects(t *testing.T) {
+	for _, pruneOrphansFlag := range []bool{false, true} {
+		err := resetForRun()
+		if err != nil {
+			t.Error("Failed to reset default flags")
+			return
+		}
+
+		filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", rand.Int()))
+		defer os.Remove(filename)
+
+		// The module of deletedModule is gone, while the one of the basic_module root still exists
+		contents := []byte(`projects:
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+    - '*.tf*'
+  dir: deletedModule
+`)
+		if err := os.WriteFile(filename, contents, 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := RunWithFlags(filename, []string{
+			"generate",
+			"--preserve-projects",
+			"--check-orphans",
+			fmt.Sprintf("--prune-orphans=%t", pruneOrphansFlag),
+			"--output",
+			filename,
+			"--root",
+			filepath.Join("..", "test_examples", "basic_module"),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		config, err := ParseAtlantisConfig(content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dirs := []string{}
+		for _, project := range config.Projects {
+			dirs = append(dirs, project.Dir)
+		}
+
+		if pruneOrphansFlag {
+			assert.Equal(t, []string{"."}, dirs)
+			assert.Empty(t, diagnostics.All())
+		} else {
+			assert.Equal(t, []string{".", "deletedModule"}, dirs)
+			assert.Equal(t, []Diagnostic{
+				{
+					Severity: SeverityWarning,
+					File:     filename,
+					Message:  "project deletedModule has no terragrunt module or project hcl file in its dir anymore",
+				},
+			}, diagnostics.All())
+		}
+	}
+}
+
+func TestManagingOnlyProjects(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	randomInt := rand.Int()
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", randomInt))
+	defer os.Remove(filename)
+
+	// Create an existing file with hand maintained keys, some of which this tool doesn't know about
+	contents := []byte(`allowed_overrides:
+- workflow
+automerge: true
+projects:
+- autoplan:
+    enabled: false
+    when_modified:
+    - '*.hcl'
+  dir: someDir
+version: 3
+workflows:
+  custom:
+    plan:
+      steps:
+      - init
+      - plan
+`)
+	os.WriteFile(filename, contents, 0644)
+
+	content, err := RunWithFlags(filename, []string{
+		"generate",
+		"--manage",
+		"projects",
+		"--preserve-projects=false",
+		"--output",
+		filename,
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+	})
+	if err != nil {
+		t.Error("Failed to read file")
+		return
+	}
+
+	goldenContents, err := os.ReadFile(filepath.Join("golden", "manageOnlyProjects.yaml"))
+	if err != nil {
+		t.Error("Failed to read golden file")
+		return
+	}
+
+	if string(content) != string(goldenContents) {
+		t.Errorf("Content did not match golden file.\n\nExpected Content: %s\n\nContent: %s", string(goldenContents), string(content))
+	}
+}
+
+func TestManagingUnknownKey(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--manage",
+		"projects,metadata",
+	})
+	err = rootCmd.Execute()
+
+	expectedError := `unknown --manage value "metadata", must be one of allow_custom_workflows, allowed_overrides, automerge, parallel, projects, version, workflows`
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("Expected error '%s', got '%v'", expectedError, err)
+	}
+}
+
+func TestValidatingOutput(t *testing.T) {
+	runTest(t, filepath.Join("golden", "workflow_steps.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "workflow_steps"),
+		"--validate-output",
+	})
+}
+
+func TestUndefinedWorkflows(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "different_workflow_names"),
+	})
+	err := rootCmd.Execute()
+	assert.EqualError(t, err, "projects use workflows that are not defined in workflows: workflowA, workflowB. Use --allow-undefined-workflows if they are defined on the Atlantis server")
+}
+
+// Workflows generated from atlantis_workflow_steps are defined
+func TestGeneratedWorkflowsAreDefined(t *testing.T) {
+	runTest(t, filepath.Join("golden", "workflow_steps.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "workflow_steps"),
+	})
+}
+
+func TestValidateAtlantisConfig(t *testing.T) {
+	err := validateAtlantisConfig(&AtlantisConfig{
+		Version: 3,
+		Projects: []AtlantisProject{
+			{Dir: "app", Name: "app", DependsOn: []string{"db"}},
+			{Dir: "other", Name: "app", ApplyRequirements: &[]string{"reviewed"}},
+			{Dir: "shared"},
+			{Dir: "shared"},
+			{Dir: "../outside", Name: "outside"},
+		},
+	})
+	assert.EqualError(t, err, `the generated config would be rejected by Atlantis:
+  project name "app" is used by more than one project
+  project app depends on project "db", which does not exist
+  project other has requirement "reviewed", which must be one of approved, mergeable, undiverged
+  there are several projects with dir shared and workspace default, they must have a name
+  project dir ../outside must be relative to the repo root and must not contain ..`)
+}
+
+func TestWorkflowSteps(t *testing.T) {
+	runTest(t, filepath.Join("golden", "workflow_steps.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "workflow_steps"),
+	})
+}
+
+func TestDedupingWorkflows(t *testing.T) {
+	root := t.TempDir()
+	workflowSteps := `locals {
+  atlantis_workflow_steps = {
+    plan = {
+      steps = ["init", { run = "terragrunt plan -out $PLANFILE" }]
+    }
+  }
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+`
+	for _, module := range []string{"app_a", "app_b", "app_c"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, module), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(root, module, "terragrunt.hcl"), []byte(workflowSteps), 0644))
+	}
+
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	filename := filepath.Join(root, "atlantis.yaml")
+	contentBytes, err := RunWithFlags(filename, []string{
+		"generate",
+		"--output",
+		filename,
+		"--root",
+		root,
+		"--dedupe-workflows",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	content := &AtlantisConfig{}
+	assert.NoError(t, yaml.Unmarshal(contentBytes, content))
+	assert.Len(t, content.Projects, 3)
+	workflows, ok := content.Workflows.(map[string]interface{})
+	assert.True(t, ok)
+	assert.Len(t, workflows, 1)
+	assert.Contains(t, workflows, "app_a_custom_workflow")
+	for _, project := range content.Projects {
+		assert.Equal(t, "app_a_custom_workflow", project.Workflow)
+	}
+}
+
+// Workflows are named after the project name when there is one, and never replace an existing workflow of the
+// same name with another definition
+func TestNamingCustomWorkflowsAfterProjects(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "app"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "app", "terragrunt.hcl"), []byte(`locals {
+  atlantis_project_name = "billing"
+  atlantis_workflow_steps = {
+    plan = {
+      steps = ["init", { run = "terragrunt plan -out $PLANFILE" }]
+    }
+  }
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+`), 0644))
+	filename := filepath.Join(root, "atlantis.yaml")
+	existing := `version: 3
+projects: []
+workflows:
+  billing_custom_workflow:
+    plan:
+      steps:
+      - run: ./plan.sh
+`
+	assert.NoError(t, os.WriteFile(filename, []byte(existing), 0644))
+
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	args := []string{
+		"generate",
+		"--output",
+		filename,
+		"--root",
+		root,
+	}
+	for run := 0; run < 2; run++ {
+		contentBytes, err := RunWithFlags(filename, args)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		// The second run finds its own workflow from the first one, which is reused
+		content := &AtlantisConfig{}
+		assert.NoError(t, yaml.Unmarshal(contentBytes, content))
+		workflows, ok := content.Workflows.(map[string]interface{})
+		assert.True(t, ok)
+		assert.Len(t, workflows, 2)
+		assert.Equal(t, map[string]interface{}{
+			"plan": map[string]interface{}{
+				"steps": []interface{}{map[string]interface{}{"run": "./plan.sh"}},
+			},
+		}, workflows["billing_custom_workflow"])
+		assert.Len(t, content.Projects, 1)
+		assert.Equal(t, "billing_custom_workflow_2", content.Projects[0].Workflow)
+	}
+}
+
+func TestDecoratingWorkflowNames(t *testing.T) {
+	runTest(t, filepath.Join("golden", "workflow_steps_decorated.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "workflow_steps"),
+		"--allow-undefined-workflows",
+		"--workflow",
+		"terragrunt",
+		"--workflow-prefix",
+		"repoA-",
+		"--workflow-suffix",
+		"-v1",
+	})
+}
+
+func TestEmittingDescriptions(t *testing.T) {
+	// Comments are lost when unmarshalling, so the raw output is compared
+	runRawTest(t, filepath.Join("golden", "descriptions.yaml"), nil, []string{
+		"--emit-descriptions",
+		"--root",
+		filepath.Join("..", "test_examples", "descriptions"),
+	})
+}
+
+func TestWorkspaceTemplateFromPath(t *testing.T) {
+	runTest(t, filepath.Join("golden", "workspace_template_path.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "workspace_template"),
+		"--workspace-template",
+		"{{ index .Segments 0 }}-{{ index .Segments 1 }}",
+	})
+}
+
+func TestWorkspaceTemplateFromLocals(t *testing.T) {
+	runTest(t, filepath.Join("golden", "workspace_template_locals.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "workspace_template"),
+		"--workspace-template",
+		"{{ .Locals.team }}_{{ .Dir }}",
+	})
+}
+
+func TestInvalidWorkspaceTemplate(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "workspace_template"),
+		"--workspace-template",
+		"{{ .Dir",
+	})
+	err = rootCmd.Execute()
+
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid --workspace-template: ") {
+		t.Errorf("Expected an invalid --workspace-template error, got '%v'", err)
+	}
+}
+
+func TestAllowedOverrides(t *testing.T) {
+	runTest(t, filepath.Join("golden", "allowed_overrides.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--allowed-overrides",
+		"workflow,apply_requirements",
+		"--allow-custom-workflows",
+	})
+}
+
+func TestEnablingAutomerge(t *testing.T) {
+	runTest(t, filepath.Join("golden", "withAutomerge.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--automerge",
+	})
+}
+
+func TestChainedDependencies(t *testing.T) {
+	runTest(t, filepath.Join("golden", "chained_dependency.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--cascade-dependencies",
+	})
+}
+
+// Dependencies cached by a run are not reused by a later run in the same process with different flags
+func TestDependenciesCachedPerOptions(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	for _, testCase := range []struct {
+		cascade bool
+		golden  string
+	}{
+		{false, "chained_dependency_no_flag.yaml"},
+		{true, "chained_dependency.yaml"},
+	} {
+		filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", rand.Int()))
+		defer os.Remove(filename)
+
+		contentBytes, err := RunWithFlags(filename, []string{
+			"generate",
+			"--output",
+			filename,
+			"--root",
+			filepath.Join("..", "test_examples", "chained_dependencies"),
+			fmt.Sprintf("--cascade-dependencies=%t", testCase.cascade),
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		content := &AtlantisConfig{}
+		assert.NoError(t, yaml.Unmarshal(contentBytes, content))
+
+		goldenContentsBytes, err := os.ReadFile(filepath.Join("golden", testCase.golden))
+		assert.NoError(t, err)
+		goldenContents := &AtlantisConfig{}
+		assert.NoError(t, yaml.Unmarshal(goldenContentsBytes, goldenContents))
+		assert.Equal(t, goldenContents, content, "--cascade-dependencies=%t", testCase.cascade)
+	}
+}
+
+func TestTracingSlowModules(t *testing.T) {
+	runTest(t, filepath.Join("golden", "chained_dependency.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--trace-slow-modules=2",
+	})
+
+	root, err := filepath.Abs(filepath.Join("..", "test_examples", "chained_dependencies"))
+	assert.NoError(t, err)
+	modules := []string{}
+	for module := range stats.moduleDurations {
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	assert.Equal(t, []string{
+		filepath.Join(root, "dependency", "terragrunt.hcl"),
+		filepath.Join(root, "depender", "terragrunt.hcl"),
+		filepath.Join(root, "depender_on_depender", "nested", "terragrunt.hcl"),
+		filepath.Join(root, "depender_on_depender", "terragrunt.hcl"),
+	}, modules)
+}
+
+func TestChainedDependenciesHiddenBehindFlag(t *testing.T) {
+	runTest(t, filepath.Join("golden", "chained_dependency_no_flag.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--cascade-dependencies=false",
+	})
+}
+
+func TestAutoplanModeOff(t *testing.T) {
+	runTest(t, filepath.Join("golden", "chained_dependency_no_flag.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--cascade-dependencies=false",
+		"--autoplan",
+		"--autoplan-mode=off",
+	})
+}
+
+func TestAutoplanModeOn(t *testing.T) {
+	runTest(t, filepath.Join("golden", "autoplan_mode_on.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--cascade-dependencies=false",
+		"--autoplan-mode=on",
+	})
+}
+
+// Only the changed module and the module depending on it are autoplanned
+func TestAutoplanModeChanged(t *testing.T) {
+	changedFiles := filepath.Join(t.TempDir(), "changed-files.txt")
+	assert.NoError(t, os.WriteFile(changedFiles, []byte("# from git diff --name-only\ndepender/terragrunt.hcl\n"), 0644))
+
+	runTest(t, filepath.Join("golden", "autoplan_mode_changed.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--cascade-dependencies=false",
+		"--autoplan",
+		"--autoplan-mode=changed",
+		"--changed-files-from",
+		changedFiles,
+	})
+}
+
+func TestAutoplanModeChangedRequiresChangedFiles(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--autoplan-mode=changed",
+	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "--autoplan-mode=changed requires --changed-files-from")
+}
+
+func TestApplyRequirementsLocals(t *testing.T) {
+	runTest(t, filepath.Join("golden", "apply_overrides.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "apply_requirements_overrides"),
+	})
+}
+
+func TestApplyRequirementsFlag(t *testing.T) {
+	runTest(t, filepath.Join("golden", "apply_overrides_flag.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--apply-requirements=approved,mergeable",
+	})
+}
+
+func TestDefaultApplyRequirementsFlagAlias(t *testing.T) {
+	runTest(t, filepath.Join("golden", "apply_overrides_flag.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--default-apply-requirements=approved,mergeable",
+	})
+}
+
+// The alias sets the same flag, so values given to both are combined
+func TestCombiningApplyRequirementsFlagAlias(t *testing.T) {
+	runTest(t, filepath.Join("golden", "apply_overrides_flag.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--apply-requirements=approved",
+		"--default-apply-requirements=mergeable",
+	})
+}
+
+func TestCombiningApplyRequirementsEnvAlias(t *testing.T) {
+	t.Setenv("TGAC_APPLY_REQUIREMENTS", "approved")
+	t.Setenv("TGAC_DEFAULT_APPLY_REQUIREMENTS", "mergeable")
+	runTest(t, filepath.Join("golden", "apply_overrides_flag.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+	})
+}
+
+// Module locals take precedence over the --apply-requirements default
+func TestApplyRequirementsLocalsOverrideFlag(t *testing.T) {
+	runTest(t, filepath.Join("golden", "apply_overrides_default.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "apply_requirements_overrides"),
+		"--apply-requirements=mergeable",
+	})
+}
+
+// --force-apply-requirements takes precedence over both module locals and the --apply-requirements default
+func TestForceApplyRequirementsFlag(t *testing.T) {
+	runTest(t, filepath.Join("golden", "apply_overrides_forced.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "apply_requirements_overrides"),
+		"--apply-requirements=mergeable",
+		"--force-apply-requirements=approved",
+	})
+}
+
+func TestMergingApplyRequirementsFlag(t *testing.T) {
+	runTest(t, filepath.Join("golden", "apply_overrides_merged.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "apply_requirements_overrides"),
+		"--apply-requirements=approved",
+		"--merge-apply-requirements",
+	})
+}
+
+// Projects of project hcl files without their own requirements use the --apply-requirements default
+func TestApplyRequirementsFlagForHclProjects(t *testing.T) {
+	runTest(t, filepath.Join("golden", "empty_project_hcl_apply_requirements.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "empty_project_hcl"),
+		"--project-hcl-files=env.hcl",
+		"--apply-requirements=approved",
+	})
+}
+
+func TestPlanRequirementsLocals(t *testing.T) {
+	runTest(t, filepath.Join("golden", "plan_requirements.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "plan_requirements"),
+	})
+}
+
+// Module locals take precedence over the --plan-requirements default
+func TestPlanRequirementsLocalsOverrideFlag(t *testing.T) {
+	runTest(t, filepath.Join("golden", "plan_requirements_flag.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "plan_requirements"),
+		"--plan-requirements=approved",
+	})
+}
+
+func TestApplyRequirementsFlagOrderIndependence(t *testing.T) {
+	for _, requirements := range []string{"mergeable,approved", "approved,mergeable,approved"} {
+		runTest(t, filepath.Join("golden", "apply_overrides_flag.yaml"), []string{
+			"--root",
+			filepath.Join("..", "test_examples", "basic_module"),
+			"--apply-requirements=" + requirements,
+		})
+	}
+}
+
+func TestCanonicalApplyRequirements(t *testing.T) {
+	assert.Nil(t, canonicalApplyRequirements(nil))
+	assert.Equal(t, &[]string{}, canonicalApplyRequirements(&[]string{}))
+	assert.Equal(t, &[]string{"approved", "mergeable"}, canonicalApplyRequirements(&[]string{"mergeable", "approved", "mergeable"}))
+}
+
+func TestFilterFlagWithInfraLiveProd(t *testing.T) {
+	runTest(t, filepath.Join("golden", "filterInfraLiveProd.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--filter",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "prod"),
+	})
+}
+
+func TestFilterFlagWithInfraLiveNonProd(t *testing.T) {
+	runTest(t, filepath.Join("golden", "filterInfraLiveNonProd.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--filter",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "non-prod"),
+	})
+}
+
+func TestFilterFlagWithInfraLiveProdAndNonProd(t *testing.T) {
+	runTest(t, filepath.Join("golden", "filterInfraLiveProdAndNonProd.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--filter",
+		strings.Join(
+			[]string{
+				filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "non-prod"),
+				filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "prod"),
+			},
+			",",
+		),
+	})
+}
+
+func TestFilterGlobFlagWithInfraLiveMySql(t *testing.T) {
+	runTest(t, filepath.Join("golden", "filterGlobInfraLiveMySQL.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--filter",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "*", "*", "*", "mysql"),
+	})
+}
+
+func TestFilterDoubleStarGlobFlagWithInfraLiveMySql(t *testing.T) {
+	runTest(t, filepath.Join("golden", "filterGlobInfraLiveMySQL.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--filter",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "**", "mysql"),
+	})
+}
+
+func TestMultipleDoubleStarGlobFilters(t *testing.T) {
+	runTest(t, filepath.Join("golden", "filterGlobInfraLiveMySQL.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--filter",
+		strings.Join(
+			[]string{
+				filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "non-prod", "**", "mysql"),
+				filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "prod", "**", "mysql"),
+			},
+			",",
+		),
+	})
+}
+
+func TestFilterFile(t *testing.T) {
+	infraLive := filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example")
+	filterFile := filepath.Join(t.TempDir(), "filters.txt")
+	contents := strings.Join([]string{
+		"# directories changed in the pull request",
+		filepath.Join(infraLive, "non-prod", "us-east-1", "qa", "mysql"),
+		"",
+		"  " + filepath.Join(infraLive, "prod", "*", "*", "mysql") + "  ",
+	}, "\n")
+	if err := os.WriteFile(filterFile, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Patterns from the file are merged with the ones from --filter
+	runTest(t, filepath.Join("golden", "filterGlobInfraLiveMySQL.yaml"), []string{
+		"--root",
+		infraLive,
+		"--filter-file",
+		filterFile,
+		"--filter",
+		filepath.Join(infraLive, "non-prod", "us-east-1", "stage", "mysql"),
+	})
+}
+
+func TestMissingFilterFile(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
+		"--filter-file",
+		filepath.Join(t.TempDir(), "missing.txt"),
+	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "could not read --filter-file")
+}
+
+// Locals of labeled includes are merged like those of a bare include, also when the include path is relative
+func TestLabeledIncludes(t *testing.T) {
+	runTest(t, filepath.Join("golden", "labeled_includes.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "labeled_includes"),
+		"--allow-undefined-workflows",
+	})
+}
+
+// Included configs without cycles are remembered, so they are only followed for the first module including them
+func TestCachingAcyclicIncludes(t *testing.T) {
+	runTest(t, filepath.Join("golden", "labeled_includes.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "labeled_includes"),
+		"--allow-undefined-workflows",
+	})
+
+	root, err := filepath.Abs(filepath.Join("..", "test_examples", "labeled_includes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, include := range []string{"root.hcl", "common.hcl"} {
+		assert.True(t, acyclicIncludesCache.has(filepath.Join(root, include)), include)
+	}
+}
+
+// The includes followed by a run are followed again by the next run in the same process, with its flags
+func TestFollowingIncludesAgainOnEachRun(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "root.hcl"), []byte("locals {\n  greeting = run_cmd(\"echo\", \"hello\")\n}\n"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "app"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "app", "terragrunt.hcl"), []byte("include \"root\" {\n  path = find_in_parent_folders(\"root.hcl\")\n}\n\nterraform {\n  source = \"git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4\"\n}\n"), 0644))
+
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		root,
+		"--output",
+		"-",
+	})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatal(err)
+	}
+
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		root,
+		"--offline",
+		"--output",
+		"-",
+	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "root.hcl calls run_cmd() on line 2, which needs network access disabled by --offline")
+}
+
+// Creates modules a and b including each other, next to a module without includes
+func createCircularIncludes(t *testing.T) string {
+	root := t.TempDir()
+	source := "terraform {\n  source = \"git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4\"\n}\n"
+	for module, include := range map[string]string{"a": "../b/terragrunt.hcl", "b": "../a/terragrunt.hcl", "c": ""} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, module), 0755))
+		contents := source
+		if include != "" {
+			contents = fmt.Sprintf("include {\n  path = %q\n}\n\n%s", include, source)
+		}
+		assert.NoError(t, os.WriteFile(filepath.Join(root, module, "terragrunt.hcl"), []byte(contents), 0644))
+	}
+	return root
+}
+
+func TestCircularIncludes(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		createCircularIncludes(t),
+		"--num-executors=1",
+		"--output",
+		"-",
+	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "circular include: a/terragrunt.hcl -> b/terragrunt.hcl -> a/terragrunt.hcl")
+}
+
+func TestKeepGoingSkipsCircularIncludes(t *testing.T) {
+	root := createCircularIncludes(t)
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	filename := filepath.Join(root, "atlantis.yaml")
+	contentBytes, err := RunWithFlags(filename, []string{
+		"generate",
+		"--output",
+		filename,
+		"--root",
+		root,
+		"--keep-going",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	content := &AtlantisConfig{}
+	assert.NoError(t, yaml.Unmarshal(contentBytes, content))
+	if assert.Len(t, content.Projects, 1) {
+		assert.Equal(t, "c", content.Projects[0].Dir)
+	}
+	assert.Len(t, diagnostics.All(), 2)
+}
+
+func TestMultipleIncludes(t *testing.T) {
+	runTest(t, filepath.Join("golden", "multiple_includes.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "multiple_includes"),
+		"--terraform-version", "0.14.9001",
+	})
+}
+
+func TestRemoteModuleSourceBitbucket(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_bitbucket"),
+	})
+}
+
+func TestRemoteModuleSourceGCS(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_gcs"),
+	})
+}
+
+func TestRemoteModuleSourceGitHTTPS(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_git_https"),
+	})
+}
+
+func TestRemoteModuleSourceGitSCPLike(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_git_scp_like"),
+	})
+}
+
+func TestRemoteModuleSourceGitSSH(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_git_ssh"),
+	})
+}
+
+func TestRemoteModuleSourceGithubHTTPS(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_github_https"),
+	})
+}
+
+func TestRemoteModuleSourceGithubSSH(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_github_ssh"),
+	})
+}
+
+func TestRemoteModuleSourceHTTP(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_http"),
+	})
+}
+
+func TestRemoteModuleSourceHTTPS(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_https"),
+	})
+}
+
+func TestRemoteModuleSourceMercurial(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_mercurial"),
+	})
+}
+
+func TestRemoteModuleSourceS3(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_s3"),
+	})
+}
+
+func TestRemoteModuleSourceTerraformRegistry(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_terraform_registry"),
+	})
+}
+
+func TestRemoteModuleSourceTerraformRegistrySubmodule(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_terraform_registry_submodule"),
+	})
+}
+
+func TestRemoteModuleSourceGitSubmodule(t *testing.T) {
+	runTest(t, filepath.Join("golden", "remote_module_source_git_submodule.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_git_submodule"),
+	})
+}
+
+// Remote sources are classified without fetching them, so a source that can never be resolved still produces the
+// basic output with --offline. The BitBucket source is also normalized so Terragrunt does not look it up when
+// searching its cache for a vendored copy.
+func TestRemoteModuleSourceOffline(t *testing.T) {
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_unreachable"),
+		"--offline",
+	})
+
+	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "remote_module_source_bitbucket"),
+		"--resolve-remote-local-submodules",
+		"--offline",
 	})
 }
 
-func TestFilterGlobFlagWithInfraLiveMySql(t *testing.T) {
-	runTest(t, filepath.Join("golden", "filterGlobInfraLiveMySQL.yaml"), []string{
+// go-getter's own detectors look BitBucket sources up over the network, which --offline must refuse
+func TestOfflineRefusesNetworkSourceResolution(t *testing.T) {
+	defer func(detectors []getter.Detector) {
+		moduleSourceDetectors = detectors
+	}(moduleSourceDetectors)
+	moduleSourceDetectors = getter.Detectors
+
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
 		"--root",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example"),
-		"--filter",
-		filepath.Join("..", "test_examples", "terragrunt-infrastructure-live-example", "*", "*", "*", "mysql"),
+		filepath.Join("..", "test_examples", "remote_module_source_bitbucket"),
+		"--offline",
+		"--output",
+		"-",
 	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "resolving module source bitbucket.org/hashicorp/tf-test-git would ask the BitBucket API, which is disabled by --offline")
+
+	// Terragrunt runs go-getter's detectors itself when looking for a vendored copy
+	moduleDir, err := filepath.Abs(filepath.Join("..", "test_examples", "remote_module_source_bitbucket"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = findVendoredModuleDir(moduleDir, "https://bitbucket.org/hashicorp/tf-test-git")
+	assert.ErrorContains(t, err, "disabled by --offline")
 }
 
-func TestMultipleIncludes(t *testing.T) {
-	runTest(t, filepath.Join("golden", "multiple_includes.yaml"), []string{
+func TestOfflineRefusesNetworkFunctions(t *testing.T) {
+	root := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "root.hcl"), []byte("locals {\n  account_id = get_aws_account_id()\n}\n"), 0644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(root, "app"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(root, "app", "terragrunt.hcl"), []byte("include \"root\" {\n  path = find_in_parent_folders(\"root.hcl\")\n}\n\nterraform {\n  source = \"git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4\"\n}\n"), 0644))
+
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
 		"--root",
-		filepath.Join("..", "test_examples", "multiple_includes"),
-		"--terraform-version", "0.14.9001",
+		root,
+		"--offline",
+		"--output",
+		"-",
 	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "root.hcl calls get_aws_account_id() on line 2, which needs network access disabled by --offline")
 }
 
-func TestRemoteModuleSourceBitbucket(t *testing.T) {
+func TestRemoteModuleVendoredLocalSubmodulesIgnoredByDefault(t *testing.T) {
 	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_bitbucket"),
+		filepath.Join("..", "test_examples", "remote_module_source_vendored"),
 	})
 }
 
-func TestRemoteModuleSourceGCS(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+// The cache also has a copy of another source, which must not be followed. The working dir hash of the cache differs
+// between machines, so it is replaced by a glob.
+func TestRemoteModuleVendoredLocalSubmodules(t *testing.T) {
+	runTest(t, filepath.Join("golden", "remote_module_source_vendored.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_gcs"),
+		filepath.Join("..", "test_examples", "remote_module_source_vendored"),
+		"--resolve-remote-local-submodules",
 	})
 }
 
-func TestRemoteModuleSourceGitHTTPS(t *testing.T) {
+func TestRemoteModuleNotVendoredLocalSubmodules(t *testing.T) {
 	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
 		"--root",
 		filepath.Join("..", "test_examples", "remote_module_source_git_https"),
+		"--resolve-remote-local-submodules",
 	})
 }
 
-func TestRemoteModuleSourceGitSCPLike(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestNotFollowingSymlinksByDefault(t *testing.T) {
+	runTest(t, filepath.Join("golden", "follow_symlinks_disabled.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_git_scp_like"),
+		filepath.Join("..", "test_examples", "follow_symlinks"),
 	})
 }
 
-func TestRemoteModuleSourceGitSSH(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+// The fixture has `linked -> real` and a self-referential `loop -> .`, which must not produce `loop/...` projects
+func TestFollowingSymlinks(t *testing.T) {
+	runTest(t, filepath.Join("golden", "follow_symlinks.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_git_ssh"),
+		filepath.Join("..", "test_examples", "follow_symlinks"),
+		"--follow-symlinks",
 	})
 }
 
-func TestRemoteModuleSourceGithubHTTPS(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+// Skipped dirs and symlinks to them are pruned when following symlinks, without also skipping their siblings
+func TestFollowingSymlinksPrunesSkippedDirs(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Fatal(err)
+	}
+	followSymlinks = true
+
+	root := t.TempDir()
+	for _, dir := range []string{"app", filepath.Join(".terragrunt-cache", "module"), "zz"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, dir), 0755))
+	}
+	assert.NoError(t, os.Symlink(".terragrunt-cache", filepath.Join(root, "cache")))
+	assert.NoError(t, os.Symlink(".", filepath.Join(root, "loop")))
+
+	walked := []string{}
+	err := walkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		walked = append(walked, filepath.ToSlash(relativePath))
+		if entry.Name() == ".terragrunt-cache" || entry.Name() == "cache" {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{".", ".terragrunt-cache", "app", "cache", "zz"}, walked)
+}
+
+func TestHclJsonConfig(t *testing.T) {
+	runTest(t, filepath.Join("golden", "hcl_json.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_github_https"),
+		filepath.Join("..", "test_examples", "hcl_json"),
+		"--allow-undefined-workflows",
 	})
 }
 
-func TestRemoteModuleSourceGithubSSH(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestSkippingOversizedFiles(t *testing.T) {
+	runTest(t, filepath.Join("golden", "max_file_size.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_github_ssh"),
+		filepath.Join("..", "test_examples", "max_file_size"),
+		"--max-file-size",
+		"1024",
 	})
 }
 
-func TestRemoteModuleSourceHTTP(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+// State and tfvars files also match `*.tf*`, but are not parsed and so must not turn off tracking local modules
+func TestMaxFileSizeIgnoresStateFiles(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Fatal(err)
+	}
+	maxFileSize = 1024
+
+	module := t.TempDir()
+	files := map[string]string{
+		"main.tf":                         "module \"child\" {\n  source = \"./child\"\n}\n",
+		filepath.Join("child", "main.tf"): "variable \"name\" {}\n",
+		"terraform.tfstate":               strings.Repeat(" ", 2048),
+		"terraform.tfstate.backup":        strings.Repeat(" ", 2048),
+	}
+	for name, contents := range files {
+		path := filepath.Join(module, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sources, err := parseTerraformLocalModuleSource(module)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []string{filepath.ToSlash(filepath.Join(module, "child", "*.tf*"))}, sources)
+	assert.Empty(t, diagnostics.All())
+}
+
+func TestCollectingDiagnostics(t *testing.T) {
+	err := resetForRun()
+	if err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+
+	filename := filepath.Join("test_artifacts", fmt.Sprintf("%d.yaml", rand.Int()))
+	defer os.Remove(filename)
+
+	_, collected, err := RunWithFlagsAndDiagnostics(filename, []string{
+		"generate",
+		"--output",
+		filename,
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_http"),
+		filepath.Join("..", "test_examples", "hcl_json"),
+		"--allow-undefined-workflows",
 	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	root, err := filepath.Abs(filepath.Join("..", "test_examples", "hcl_json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Diagnostic{
+		{
+			Severity: SeverityWarning,
+			File:     filepath.Join(root, "json_expanded", "terragrunt.hcl.json"),
+			Message:  fmt.Sprintf("dependency %s has no terragrunt config", filepath.Join(root, "someRandomDir")),
+		},
+	}, collected)
 }
 
-func TestRemoteModuleSourceHTTPS(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestDroppingEmptyHclProjects(t *testing.T) {
+	runTest(t, filepath.Join("golden", "empty_project_hcl.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_https"),
+		filepath.Join("..", "test_examples", "empty_project_hcl"),
+		"--project-hcl-files=env.hcl",
 	})
 }
 
-func TestRemoteModuleSourceMercurial(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestKeepingEmptyHclProjects(t *testing.T) {
+	runTest(t, filepath.Join("golden", "empty_project_hcl_kept.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_mercurial"),
+		filepath.Join("..", "test_examples", "empty_project_hcl"),
+		"--project-hcl-files=env.hcl",
+		"--keep-empty-hcl-projects",
 	})
 }
 
-func TestRemoteModuleSourceS3(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestWhenModifiedRelativeToProjectDir(t *testing.T) {
+	runTest(t, filepath.Join("golden", "siblingDependencies.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_s3"),
+		filepath.Join("..", "test_examples", "sibling_dependencies"),
 	})
 }
 
-func TestRemoteModuleSourceTerraformRegistry(t *testing.T) {
-	runTest(t, filepath.Join("golden", "basic.yaml"), []string{
+func TestWhenModifiedPortableAcrossCheckouts(t *testing.T) {
+	// The same repo checked out at prefixes of different depths generates the same config
+	for _, prefix := range []string{"checkout", filepath.Join("ci", "builds", "1234", "checkout")} {
+		root := filepath.Join(t.TempDir(), prefix)
+		if err := os.CopyFS(root, os.DirFS(filepath.Join("..", "test_examples", "sibling_dependencies"))); err != nil {
+			t.Fatal(err)
+		}
+
+		runTest(t, filepath.Join("golden", "siblingDependencies.yaml"), []string{
+			"--root",
+			root,
+		})
+	}
+}
+
+func TestWhenModifiedRelativeToProjectHclDir(t *testing.T) {
+	runTest(t, filepath.Join("golden", "siblingDependenciesEnvHcl.yaml"), []string{
 		"--root",
-		filepath.Join("..", "test_examples", "remote_module_source_terraform_registry"),
+		filepath.Join("..", "test_examples", "sibling_dependencies"),
+		"--project-hcl-files=env.hcl",
 	})
 }
 
@@ -604,6 +3015,7 @@ func TestEnvHCLProjectsNoChilds(t *testing.T) {
 	runTest(t, filepath.Join("golden", "envhcl_nochilds.yaml"), []string{
 		"--root",
 		filepath.Join("..", "test_examples"),
+		"--allow-undefined-workflows",
 		"--project-hcl-files=env.hcl",
 		"--create-hcl-project-childs=false",
 		"--create-hcl-project-external-childs=false",
@@ -614,6 +3026,7 @@ func TestEnvHCLProjectsSubChilds(t *testing.T) {
 	runTest(t, filepath.Join("golden", "envhcl_subchilds.yaml"), []string{
 		"--root",
 		filepath.Join("..", "test_examples"),
+		"--allow-undefined-workflows",
 		"--project-hcl-files=env.hcl",
 		"--create-hcl-project-childs=true",
 		"--create-hcl-project-external-childs=false",
@@ -624,6 +3037,7 @@ func TestEnvHCLProjectsExternalChilds(t *testing.T) {
 	runTest(t, filepath.Join("golden", "envhcl_externalchilds.yaml"), []string{
 		"--root",
 		filepath.Join("..", "test_examples"),
+		"--allow-undefined-workflows",
 		"--project-hcl-files=env.hcl",
 		"--create-hcl-project-childs=false",
 		"--create-hcl-project-external-childs=true",
@@ -634,6 +3048,7 @@ func TestEnvHCLProjectsAllChilds(t *testing.T) {
 	runTest(t, filepath.Join("golden", "envhcl_allchilds.yaml"), []string{
 		"--root",
 		filepath.Join("..", "test_examples"),
+		"--allow-undefined-workflows",
 		"--project-hcl-files=env.hcl",
 		"--create-hcl-project-childs=true",
 		"--create-hcl-project-external-childs=true",
@@ -644,6 +3059,7 @@ func TestEnvHCLProjectMarker(t *testing.T) {
 	runTest(t, filepath.Join("golden", "project_marker.yaml"), []string{
 		"--root",
 		filepath.Join("..", "test_examples", "project_hcl_with_project_marker"),
+		"--allow-undefined-workflows",
 		"--project-hcl-files=env.hcl",
 		"--use-project-markers=true",
 	})
@@ -664,6 +3080,75 @@ func TestWithExecutionOrderGroups(t *testing.T) {
 	})
 }
 
+func TestExecutionOrderStep(t *testing.T) {
+	runTest(t, filepath.Join("golden", "withExecutionOrderStep.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "chained_dependencies"),
+		"--execution-order-groups",
+		"--execution-order-step=10",
+	})
+}
+
+func TestExecutionOrderGroupsForProjectHclProjects(t *testing.T) {
+	runTest(t, filepath.Join("golden", "project_hcl_dependencies.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "project_hcl_dependencies"),
+		"--project-hcl-files=env.hcl",
+		"--execution-order-groups",
+	})
+}
+
+func TestIsolationGroupsNeverShareExecutionOrderGroups(t *testing.T) {
+	runTest(t, filepath.Join("golden", "isolation_groups.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "isolation_groups"),
+		"--execution-order-groups",
+	})
+}
+
+// Only the computed groups after a split are shifted, keeping the gaps of --execution-order-step and pinned groups
+func TestSeparatingIsolationGroupsKeepsPinnedGroups(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Fatal(err)
+	}
+	executionOrderStep = 10
+
+	group := func(group int) *int {
+		return &group
+	}
+	projects := []AtlantisProject{
+		{Dir: "network", ExecutionOrderGroup: group(0)},
+		{Dir: "account_a/app", ExecutionOrderGroup: group(10), isolationGroup: "account-a"},
+		{Dir: "account_b/app", ExecutionOrderGroup: group(10), isolationGroup: "account-b"},
+		{Dir: "pinned", ExecutionOrderGroup: group(15), executionOrderGroup: group(15), isolationGroup: "account-b"},
+		{Dir: "dns", ExecutionOrderGroup: group(20)},
+		{Dir: "pinned_a", ExecutionOrderGroup: group(40), executionOrderGroup: group(40), isolationGroup: "account-a"},
+		{Dir: "pinned_b", ExecutionOrderGroup: group(40), executionOrderGroup: group(40), isolationGroup: "account-b"},
+	}
+	separateIsolationGroups(projects)
+
+	groups := map[string]int{}
+	for _, project := range projects {
+		groups[project.Dir] = *project.ExecutionOrderGroup
+	}
+	assert.Equal(t, map[string]int{
+		"network":       0,
+		"account_a/app": 10,
+		"account_b/app": 20,
+		"pinned":        15,
+		"dns":           30,
+		"pinned_a":      40,
+		"pinned_b":      40,
+	}, groups)
+	assert.Equal(t, []Diagnostic{
+		{
+			Severity: SeverityWarning,
+			File:     gitRoot,
+			Message:  "isolation groups account-a, account-b share execution_order_group 40, which is pinned by atlantis_execution_order_group",
+		},
+	}, diagnostics.All())
+}
+
 func TestWithExecutionOrderGroupsAndDependsOn(t *testing.T) {
 	runTest(t, filepath.Join("golden", "withExecutionOrderGroupsAndDependsOn.yaml"), []string{
 		"--root",
@@ -682,3 +3167,407 @@ func TestWithDependsOn(t *testing.T) {
 		"--create-project-name",
 	})
 }
+
+func TestParseHclReturnsPanicsAsErrors(t *testing.T) {
+	// A nil parser panics inside the HCL library, which must surface as an error instead of a crash or a nil file
+	file, err := parseHcl(nil, "locals {}", "terragrunt.hcl")
+
+	assert.Nil(t, file)
+	assert.IsType(t, hclparse.PanicWhileParsingConfigError{}, errors.Unwrap(err))
+	assert.Contains(t, err.Error(), "terragrunt.hcl")
+}
+
+// Creates a tree of directories with many non-config files, each directory containing a terragrunt.hcl and env.hcl
+func createLargeTree(b *testing.B) string {
+	root := b.TempDir()
+	for i := 0; i < 200; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("account-%d", i%10), fmt.Sprintf("module-%d", i))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			b.Fatal(err)
+		}
+		for _, name := range []string{"terragrunt.hcl", "env.hcl"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte{}, 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+		for j := 0; j < 50; j++ {
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("file-%d.tf", j)), []byte{}, 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+	return root
+}
+
+func BenchmarkDiscoverFiles(b *testing.B) {
+	root := createLargeTree(b)
+	if err := resetForRun(); err != nil {
+		b.Fatal(err)
+	}
+	defer resetForRun()
+	projectHclFiles = []string{"env.hcl"}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		discovered, err := discoverFiles(root)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(discovered.configFiles) != 200 || len(discovered.projectHclDirs["env.hcl"]) != 200 {
+			b.Fatalf("expected 200 modules and env.hcl files, found %d and %d", len(discovered.configFiles), len(discovered.projectHclDirs["env.hcl"]))
+		}
+	}
+}
+
+func TestLocalModuleParseErrorPosition(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Fatal(err)
+	}
+	moduleDir := t.TempDir()
+	err := os.WriteFile(filepath.Join(moduleDir, "main.tf"), []byte("variable \"name\" {}\n\nmodule \"broken\" {\n  source = \"../shared\"\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The unclosed block is reported where it starts
+	_, err = parseTerraformLocalModuleSource(moduleDir)
+	assert.ErrorContains(t, err, filepath.Join(moduleDir, "main.tf")+":3")
+
+	var diags tfconfig.Diagnostics
+	if assert.ErrorAs(t, err, &diags) {
+		assert.True(t, diags.HasErrors())
+		assert.Equal(t, 3, diags[0].Pos.Line)
+	}
+}
+
+// Creates a module using a local Terraform module that calls another module from unparseable Terraform
+func createUnparseableModule(t *testing.T) string {
+	root := t.TempDir()
+	files := map[string]string{
+		filepath.Join("live", "terragrunt.hcl"):       "terraform {\n  source = \"../modules/app\"\n}\n",
+		filepath.Join("modules", "app", "main.tf"):    "module \"broken\" {\n  source = \"../broken\"\n}\n",
+		filepath.Join("modules", "broken", "main.tf"): "module \"shared\" {\n  source = \"../shared\"\n",
+		filepath.Join("modules", "shared", "main.tf"): "variable \"name\" {}\n",
+	}
+	for name, contents := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestIgnoringTfParseErrors(t *testing.T) {
+	runTest(t, filepath.Join("golden", "ignoreTfParseErrors.yaml"), []string{
+		"--root",
+		createUnparseableModule(t),
+		"--ignore-tf-parse-errors",
+	})
+
+	warnings := diagnostics.All()
+	if assert.Len(t, warnings, 1) {
+		assert.Contains(t, warnings[0].Message, "failed to parse local module")
+	}
+}
+
+func TestFailingOnTfParseErrors(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		createUnparseableModule(t),
+	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, "failed to parse local module")
+}
+
+func TestSharedSubmodule(t *testing.T) {
+	runTest(t, filepath.Join("golden", "sharedSubmodule.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "shared_submodule"),
+	})
+}
+
+// A submodule called by several modules must only be parsed once
+func TestParsingSharedSubmoduleOnce(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Fatal(err)
+	}
+	modulesDir := filepath.Join("..", "test_examples", "shared_submodule", "modules")
+
+	for _, module := range []string{"app", "worker"} {
+		sources, err := parseTerraformLocalModuleSource(filepath.Join(modulesDir, module))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, []string{filepath.ToSlash(filepath.Join(modulesDir, "shared", "*.tf*"))}, sources)
+	}
+
+	// app, worker and the shared module they both call
+	assert.Len(t, moduleSourcesCache.data, 3)
+}
+
+func benchmarkParsingSharedSubmodule(b *testing.B, cached bool) {
+	if err := resetForRun(); err != nil {
+		b.Fatal(err)
+	}
+	modulesDir := filepath.Join("..", "test_examples", "shared_submodule", "modules")
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if !cached {
+			moduleSourcesCache = newModuleSourcesCache()
+		}
+		for _, module := range []string{"app", "worker"} {
+			if _, err := parseTerraformLocalModuleSource(filepath.Join(modulesDir, module)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+func BenchmarkParsingSharedSubmodule(b *testing.B) {
+	benchmarkParsingSharedSubmodule(b, true)
+}
+
+// Baseline for BenchmarkParsingSharedSubmodule, parsing every module again
+func BenchmarkParsingSharedSubmoduleUncached(b *testing.B) {
+	benchmarkParsingSharedSubmodule(b, false)
+}
+
+// Baseline for BenchmarkDiscoverFiles: Terragrunt's module discovery plus a separate walk for project hcl files
+func BenchmarkSeparateWalks(b *testing.B) {
+	root := createLargeTree(b)
+	opts, err := options.NewTerragruntOptionsWithConfigPath(root)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := config.FindConfigFilesInPath(root, opts); err != nil {
+			b.Fatal(err)
+		}
+		err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+			return err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// The single pass discovery must find exactly what Terragrunt's module discovery and a separate walk for project hcl
+// files find
+func TestDiscoverFilesMatchesSeparateWalks(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Fatal(err)
+	}
+	defer resetForRun()
+	projectHclFiles = []string{"env.hcl"}
+
+	root, err := filepath.Abs(filepath.Join("..", "test_examples"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	discovered, err := discoverFiles(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := options.NewTerragruntOptionsWithConfigPath(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configFiles, err := config.FindConfigFilesInPath(root, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootConfigs := []string{}
+	projectHclDirs := []string{}
+	err = filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() && entry.Name() == "root.hcl" {
+			rootConfigs = append(rootConfigs, path)
+		}
+		if err == nil && !entry.IsDir() && entry.Name() == "env.hcl" {
+			projectHclDirs = append(projectHclDirs, filepath.Dir(path))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotEmpty(t, configFiles)
+	assert.Equal(t, append(rootConfigs, configFiles...), discovered.configFiles)
+	assert.NotEmpty(t, projectHclDirs)
+	assert.Equal(t, projectHclDirs, discovered.projectHclDirs["env.hcl"])
+}
+
+func TestTerragruntCompatProfileSelectsRootConfigs(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Fatal(err)
+	}
+	defer resetForRun()
+
+	root, err := filepath.Abs(filepath.Join("..", "test_examples", "tags"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootConfig := filepath.Join(root, "team_x", "root.hcl")
+
+	discovered, err := discoverFiles(root)
+	assert.NoError(t, err)
+	assert.Contains(t, discovered.configFiles, rootConfig)
+
+	terragruntCompat = "legacy"
+	discovered, err = discoverFiles(root)
+	assert.NoError(t, err)
+	assert.NotContains(t, discovered.configFiles, rootConfig)
+	assert.Contains(t, discovered.configFiles, filepath.Join(root, "shared", "terragrunt.hcl"))
+}
+
+func TestUnknownTerragruntCompatProfile(t *testing.T) {
+	if err := resetForRun(); err != nil {
+		t.Error("Failed to reset default flags")
+		return
+	}
+	rootCmd.SetArgs([]string{
+		"generate",
+		"--root",
+		filepath.Join("..", "test_examples", "basic_module"),
+		"--terragrunt-compat=v0.50",
+	})
+	err := rootCmd.Execute()
+	assert.ErrorContains(t, err, `unknown --terragrunt-compat value "v0.50", must be one of legacy, modern`)
+}
+
+func TestAffectedProjects(t *testing.T) {
+	cfg := &AtlantisConfig{
+		Projects: []AtlantisProject{
+			{
+				Dir: "prod/app",
+				Autoplan: AutoplanConfig{
+					WhenModified: []string{"*.hcl", "*.tf*", "../../modules/app/*.tf*"},
+				},
+			},
+			{
+				Dir: "prod",
+				Autoplan: AutoplanConfig{
+					WhenModified: []string{"**/*.hcl"},
+				},
+			},
+			{
+				Dir: ".",
+				Autoplan: AutoplanConfig{
+					WhenModified: []string{"*.hcl"},
+				},
+			},
+		},
+	}
+
+	projectDirs := func(projects []AtlantisProject) []string {
+		dirs := []string{}
+		for _, project := range projects {
+			dirs = append(dirs, project.Dir)
+		}
+		return dirs
+	}
+
+	testCases := []struct {
+		changedFiles []string
+		expected     []string
+	}{
+		{[]string{"prod/app/terragrunt.hcl"}, []string{"prod/app", "prod"}},
+		{[]string{"prod/app/main.tf"}, []string{"prod/app"}},
+		{[]string{"modules/app/variables.tf"}, []string{"prod/app"}},
+		{[]string{"./modules/app/../app/outputs.tf"}, []string{"prod/app"}},
+		{[]string{"prod/app/nested/deep/env.hcl"}, []string{"prod"}},
+		{[]string{"root.hcl"}, []string{"."}},
+		{[]string{"modules/other/main.tf", "prod/app/README.md"}, []string{}},
+		{[]string{}, []string{}},
+	}
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.expected, projectDirs(AffectedProjects(cfg, testCase.changedFiles)), "changed files %v", testCase.changedFiles)
+	}
+}
+
+func TestAffectedProjectsWithNegatedEntries(t *testing.T) {
+	cfg := &AtlantisConfig{
+		Projects: []AtlantisProject{
+			{
+				Dir: "prod/app",
+				Autoplan: AutoplanConfig{
+					WhenModified: []string{"*.hcl", "../shared/**/*.tf", "!../shared/generated/**"},
+				},
+			},
+		},
+	}
+
+	assert.Len(t, AffectedProjects(cfg, []string{"prod/shared/main.tf"}), 1)
+	assert.Empty(t, AffectedProjects(cfg, []string{"prod/shared/generated/backend.tf"}))
+	assert.Len(t, AffectedProjects(cfg, []string{"prod/shared/generated/backend.tf", "prod/app/terragrunt.hcl"}), 1)
+}
+
+func TestSortingProjectsByDir(t *testing.T) {
+	runTest(t, filepath.Join("golden", "sortProjectsByDir.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "sort_projects"),
+		"--create-project-name",
+	})
+}
+
+func TestSortingProjectsByName(t *testing.T) {
+	runTest(t, filepath.Join("golden", "sortProjectsByName.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "sort_projects"),
+		"--create-project-name",
+		"--sort-projects-by=name",
+	})
+}
+
+func TestSortingProjectsByExecutionOrder(t *testing.T) {
+	runTest(t, filepath.Join("golden", "sortProjectsByExecutionOrder.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "sort_projects"),
+		"--create-project-name",
+		"--execution-order-groups",
+		"--sort-projects-by=execution-order",
+	})
+}
+
+func TestSortingProjectsByDirWithExecutionOrderGroups(t *testing.T) {
+	runTest(t, filepath.Join("golden", "sortProjectsByDirWithExecutionOrderGroups.yaml"), []string{
+		"--root",
+		filepath.Join("..", "test_examples", "sort_projects"),
+		"--create-project-name",
+		"--execution-order-groups",
+		"--sort-projects-by=dir",
+	})
+}
+
+func TestInvalidSortProjectsBy(t *testing.T) {
+	for _, args := range [][]string{
+		{"--sort-projects-by=size"},
+		{"--sort-projects-by=execution-order"},
+	} {
+		if err := resetForRun(); err != nil {
+			t.Error("Failed to reset default flags")
+			return
+		}
+		rootCmd.SetArgs(append([]string{
+			"generate",
+			"--root",
+			filepath.Join("..", "test_examples", "sort_projects"),
+		}, args...))
+		err := rootCmd.Execute()
+		assert.Error(t, err, "args %v", args)
+	}
+}
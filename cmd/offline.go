@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+)
+
+// Module sources are classified purely from their text with the detectors below and are never fetched, so the only
+// network access left while generating is what Terragrunt functions do when configs are evaluated. These are the
+// functions that go over the network or run commands that may.
+var networkFunctions = map[string]bool{
+	"get_aws_account_alias":           true,
+	"get_aws_account_id":              true,
+	"get_aws_caller_identity_arn":     true,
+	"get_aws_caller_identity_user_id": true,
+	"run_cmd":                         true,
+	"sops_decrypt_file":               true,
+}
+
+// A config refused by `--offline`
+type offlineConfigError struct {
+	path     string
+	function string
+	line     int
+}
+
+func (err offlineConfigError) Error() string {
+	return fmt.Sprintf("%s calls %s() on line %d, which needs network access disabled by --offline", err.path, err.function, err.line)
+}
+
+// With `--offline`, refuses a config calling a Terragrunt function that would go over the network once it is
+// evaluated. Only the config itself is checked: configs it reads with read_terragrunt_config and the outputs of its
+// dependencies are not, and neither are JSON configs.
+func checkOfflineConfig(file *hcl.File, path string) error {
+	if !offline {
+		return nil
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil
+	}
+
+	var err error
+	hclsyntax.VisitAll(body, func(node hclsyntax.Node) hcl.Diagnostics {
+		call, ok := node.(*hclsyntax.FunctionCallExpr)
+		if ok && err == nil && networkFunctions[call.Name] {
+			err = offlineConfigError{path: path, function: call.Name, line: call.Range().Start.Line}
+		}
+		return nil
+	})
+	return err
+}
+
+// go-getter's BitBucketDetector asks the BitBucket API whether a repository uses git or mercurial. BitBucket only
+// hosts git repositories since 2020, so this detector answers like the API would from the source text alone. Sources
+// normalized by it are also left alone by the detectors Terragrunt runs on them.
+type bitBucketSourceDetector struct{}
+
+func (bitBucketSourceDetector) Detect(src, _ string) (string, bool, error) {
+	if !strings.HasPrefix(src, "bitbucket.org/") {
+		return "", false, nil
+	}
+
+	u, err := url.Parse("https://" + src)
+	if err != nil {
+		return "", true, fmt.Errorf("error parsing BitBucket URL: %w", err)
+	}
+	if !strings.HasSuffix(u.Path, ".git") {
+		u.Path += ".git"
+	}
+	return "git::" + u.String(), true, nil
+}
+
+// The go-getter detectors used to classify module sources, none of which touch the network
+var moduleSourceDetectors = []getter.Detector{
+	new(getter.GitHubDetector),
+	new(getter.GitLabDetector),
+	new(getter.GitDetector),
+	new(bitBucketSourceDetector),
+	new(getter.S3Detector),
+	new(getter.GCSDetector),
+	new(getter.FileDetector),
+}
+
+// Stands in for go-getter's BitBucketDetector under `--offline`, refusing the sources it would look up over the network
+type offlineBitBucketDetector struct{}
+
+func (offlineBitBucketDetector) Detect(src, _ string) (string, bool, error) {
+	if strings.HasPrefix(src, "bitbucket.org/") {
+		return "", false, fmt.Errorf("resolving module source %s would ask the BitBucket API, which is disabled by --offline", src)
+	}
+
+	return "", false, nil
+}
+
+// Normalizes a module source with the given go-getter detectors. Under `--offline`, the detectors that go over the
+// network are replaced by ones refusing to.
+func detectModuleSource(source string, pwd string, detectors []getter.Detector) (string, error) {
+	if offline {
+		guarded := []getter.Detector{}
+		for _, detector := range detectors {
+			if _, ok := detector.(*getter.BitBucketDetector); ok {
+				detector = offlineBitBucketDetector{}
+			}
+			guarded = append(guarded, detector)
+		}
+		detectors = guarded
+	}
+
+	return getter.Detect(source, pwd, detectors)
+}
+
+// The http scheme Terragrunt removes from sources before resolving them
+var httpSchemeRegex = regexp.MustCompile(`(?i)^https?://`)
+
+// Terragrunt resolves sources with go-getter's own detectors, after removing any http scheme. Under `--offline`, this
+// resolves the source the same way beforehand, so a source Terragrunt would look up over the network is refused.
+func checkTerragruntSourceOffline(source string, pwd string) error {
+	if !offline {
+		return nil
+	}
+
+	_, err := detectModuleSource(httpSchemeRegex.ReplaceAllString(source, ""), pwd, getter.Detectors)
+	return err
+}
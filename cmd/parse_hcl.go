@@ -1,24 +1,28 @@
 package cmd
 
 import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
 	"github.com/gruntwork-io/go-commons/errors"
 	"github.com/gruntwork-io/terragrunt/config"
 	"github.com/gruntwork-io/terragrunt/config/hclparse"
 	"github.com/gruntwork-io/terragrunt/util"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/gohcl"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
+	log "github.com/sirupsen/logrus"
+	"github.com/zclconf/go-cty/cty"
 	"path/filepath"
 	_ "unsafe"
 )
 
 const bareIncludeKey = ""
 
-type parsedHcl struct {
-	Terraform *config.TerraformConfig `hcl:"terraform,block"`
-	Includes  []config.IncludeConfig  `hcl:"include,block"`
-}
-
 // terragruntIncludeMultiple is a struct that can be used to only decode the include block with labels.
 type terragruntIncludeMultiple struct {
 	Include []config.IncludeConfig `hcl:"include,block"`
@@ -137,6 +141,9 @@ func parseModule(ctx *config.ParsingContext, path string) (isParent bool, includ
 	if err != nil {
 		return false, nil, err
 	}
+	if err := checkOfflineConfig(file, path); err != nil {
+		return false, nil, err
+	}
 
 	terragruntIncludeList, err := decodeAsTerragruntInclude(ctx, file, path)
 	if err != nil {
@@ -145,18 +152,338 @@ func parseModule(ctx *config.ParsingContext, path string) (isParent bool, includ
 
 	// If the file has any `include` blocks it is not a parent
 	if len(terragruntIncludeList) > 0 {
+		log.Debugf("%s is a module, as it includes %d config(s)", path, len(terragruntIncludeList))
 		return false, terragruntIncludeList, nil
 	}
 
-	// We don't need to check the errors/diagnostics coming from `decodeHcl`, as when errors come up,
-	// it will leave the partially parsed result in the output object.
-	var parsed parsedHcl
-	_ = decodeHcl(ctx, file, path, &parsed)
-
-	// If the file does not define a terraform source block, it is likely a parent (though not guaranteed)
-	if parsed.Terraform == nil || parsed.Terraform.Source == nil {
+	// A config with `terraform.source` is runnable. Without it, a config only made of blocks like `locals`, `inputs`,
+	// `generate` or `remote_state` is an abstract parent meant to be included by the modules below it. This only looks
+	// at the blocks present, so parents whose locals can't be evaluated on their own are still recognized.
+	if !hasTerraformSource(file) {
+		// Terragrunt runs a config without a source in its own dir, next to the files its `generate` blocks write. With
+		// terraform code generated or already there, it is a runnable module rather than a parent.
+		if generatesTerraformCode(file) {
+			log.Debugf("%s is a runnable module, as it has no terraform source but generate blocks writing terraform code", path)
+			return false, nil, nil
+		}
+		if moduleFiles, _ := findModuleFiles(filepath.Dir(path)); len(moduleFiles) > 0 {
+			log.Debugf("%s is a runnable module, as it has no terraform source but terraform files in its dir", path)
+			return false, nil, nil
+		}
+		log.Debugf("%s is an abstract parent config, as it has neither include blocks, a terraform source nor terraform code in its dir", path)
 		return true, nil, nil
 	}
 
+	log.Debugf("%s is a runnable module, as it has a terraform source", path)
 	return false, nil, nil
 }
+
+// Caches the included configs known to reach no include cycle, so configs included by many modules, like the root
+// config, are only read and followed once
+type AcyclicIncludesCache struct {
+	mtx  sync.RWMutex
+	data map[string]bool
+}
+
+func newAcyclicIncludesCache() *AcyclicIncludesCache {
+	return &AcyclicIncludesCache{data: map[string]bool{}}
+}
+
+func (m *AcyclicIncludesCache) set(k string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.data[k] = true
+}
+
+func (m *AcyclicIncludesCache) has(k string) bool {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	return m.data[k]
+}
+
+var acyclicIncludesCache = newAcyclicIncludesCache()
+
+// Follows the includes of the last config of chain, returning an error naming the files of the first include cycle.
+// Terragrunt only reports these as too many levels of includes, without saying which files are involved.
+func checkIncludeCycle(ctx *config.ParsingContext, chain []string, includes []config.IncludeConfig) error {
+	current := chain[len(chain)-1]
+	for _, include := range includes {
+		includePath := include.Path
+		if !filepath.IsAbs(includePath) {
+			includePath = filepath.Join(filepath.Dir(current), includePath)
+		}
+		includePath = filepath.Clean(includePath)
+
+		for i, file := range chain {
+			if file != includePath {
+				continue
+			}
+			cycle := []string{}
+			for _, cycleFile := range append(chain[i:], includePath) {
+				if relativeFile, err := filepath.Rel(gitRoot, cycleFile); err == nil {
+					cycleFile = relativeFile
+				}
+				cycle = append(cycle, filepath.ToSlash(cycleFile))
+			}
+			return fmt.Errorf("circular include: %s", strings.Join(cycle, " -> "))
+		}
+
+		// A cycle reachable from an included config would have been found when it was followed before
+		if acyclicIncludesCache.has(includePath) {
+			continue
+		}
+		// Other errors of included configs are reported by Terragrunt when parsing the module, but it would evaluate an
+		// included config refused by `--offline` without complaint
+		_, nestedIncludes, err := parseModule(ctx, includePath)
+		if _, refused := err.(offlineConfigError); refused {
+			return err
+		} else if err != nil {
+			continue
+		}
+		nextChain := append(append([]string{}, chain...), includePath)
+		if err := checkIncludeCycle(ctx, nextChain, nestedIncludes); err != nil {
+			return err
+		}
+		acyclicIncludesCache.set(includePath)
+	}
+	return nil
+}
+
+// If the config has a `source` attribute in a `terraform` block, whatever its value
+func hasTerraformSource(file *hcl.File) bool {
+	content, _, _ := file.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "terraform"}},
+	})
+	if content == nil {
+		return false
+	}
+
+	for _, block := range content.Blocks {
+		terraformContent, _, _ := block.Body.PartialContent(&hcl.BodySchema{
+			Attributes: []hcl.AttributeSchema{{Name: "source"}},
+		})
+		if terraformContent == nil {
+			continue
+		}
+		if _, ok := terraformContent.Attributes["source"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches the start of a block declaring infrastructure in Terraform code
+var terraformCodeRegex = regexp.MustCompile(`(?m)^\s*(resource|data|module)\s+"`)
+
+// If a `generate` block of the config writes Terraform code declaring resources, data sources or modules to a module
+// file like `main.tf`. Blocks only writing things like the provider or backend don't count, as parents generate those
+// for the modules including them.
+func generatesTerraformCode(file *hcl.File) bool {
+	content, _, _ := file.Body.PartialContent(&hcl.BodySchema{
+		Blocks: []hcl.BlockHeaderSchema{{Type: "generate", LabelNames: []string{"name"}}},
+	})
+	if content == nil {
+		return false
+	}
+
+	for _, block := range content.Blocks {
+		generateContent, _, _ := block.Body.PartialContent(&hcl.BodySchema{
+			Attributes: []hcl.AttributeSchema{{Name: "path"}, {Name: "contents"}},
+		})
+		if generateContent == nil {
+			continue
+		}
+		pathAttribute, hasPath := generateContent.Attributes["path"]
+		contentsAttribute, hasContents := generateContent.Attributes["contents"]
+		if !hasPath || !hasContents {
+			continue
+		}
+		generatedPath, diags := pathAttribute.Expr.Value(nil)
+		if diags.HasErrors() || generatedPath.Type() != cty.String || generatedPath.IsNull() || !isModuleFile(generatedPath.AsString()) {
+			continue
+		}
+		if terraformCodeRegex.MatchString(literalText(contentsAttribute.Expr)) {
+			return true
+		}
+	}
+	return false
+}
+
+// The literal text of a string or template expression, leaving out its interpolations
+func literalText(expr hcl.Expression) string {
+	if template, ok := expr.(*hclsyntax.TemplateExpr); ok {
+		text := ""
+		for _, part := range template.Parts {
+			if literal, ok := part.(*hclsyntax.LiteralValueExpr); ok && literal.Val.Type() == cty.String {
+				text += literal.Val.AsString()
+			}
+		}
+		return text
+	}
+
+	value, diags := expr.Value(nil)
+	if diags.HasErrors() || value.Type() != cty.String || value.IsNull() {
+		return ""
+	}
+	return value.AsString()
+}
+
+// Finds the files read with `file()` and `templatefile()` by the `contents` of the `generate` blocks of the terragrunt
+// config at path, as changing them changes the generated code. The config is either the module at modulePath or one
+// of the configs it includes. Like in Terragrunt, relative paths and `get_terragrunt_dir()` are relative to the module
+// even in an included config, while `get_parent_terragrunt_dir()` is the dir of the included config. Paths made of
+// anything else are skipped with a warning.
+func findGenerateBlockFiles(path string, modulePath string) []string {
+	readFiles := []string{}
+	// JSON configs have no function call syntax to look for
+	if filepath.Ext(path) == ".json" {
+		return readFiles
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return readFiles
+	}
+	// The config was already parsed by Terragrunt, so errors here are not expected
+	file, diags := hclsyntax.ParseConfig(contents, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return readFiles
+	}
+
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		attribute, ok := block.Body.Attributes["contents"]
+		if block.Type != "generate" || !ok {
+			continue
+		}
+
+		hclsyntax.VisitAll(attribute.Expr, func(node hclsyntax.Node) hcl.Diagnostics {
+			call, ok := node.(*hclsyntax.FunctionCallExpr)
+			if !ok || !fileReadingFunctions[call.Name] || len(call.Args) == 0 {
+				return nil
+			}
+
+			readFile, ok := staticTerragruntPath(call.Args[0], filepath.Dir(modulePath), filepath.Dir(path))
+			if !ok {
+				diagnostics.Warnf(path, "not tracking the file read by %s() at line %d of a generate block, as its path is not static", call.Name, call.Range().Start.Line)
+				return nil
+			}
+			if !filepath.IsAbs(readFile) {
+				readFile = util.JoinPath(filepath.Dir(modulePath), readFile)
+			}
+			readFiles = append(readFiles, filepath.ToSlash(readFile))
+			return nil
+		})
+	}
+
+	return readFiles
+}
+
+// Terragrunt functions whose first argument is the path of a config they read
+var configReadingFunctions = map[string]bool{
+	"read_terragrunt_config": true,
+	"read_tfvars_file":       true,
+}
+
+// Finds the files read with `read_terragrunt_config()` and `read_tfvars_file()` anywhere in the terragrunt config at
+// path, as their values change the module. Like findGenerateBlockFiles, the config is either the module at modulePath
+// or one of the configs it includes. Besides static paths, `find_in_parent_folders()` of a string literal is looked up
+// from the module. Paths made of anything else are skipped with a warning.
+func findReadConfigFiles(path string, modulePath string) []string {
+	readFiles := []string{}
+	// JSON configs have no function call syntax to look for
+	if filepath.Ext(path) == ".json" {
+		return readFiles
+	}
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return readFiles
+	}
+	// The config was already parsed by Terragrunt, so errors here are not expected
+	file, diags := hclsyntax.ParseConfig(contents, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return readFiles
+	}
+
+	hclsyntax.VisitAll(file.Body.(*hclsyntax.Body), func(node hclsyntax.Node) hcl.Diagnostics {
+		call, ok := node.(*hclsyntax.FunctionCallExpr)
+		if !ok || !configReadingFunctions[call.Name] || len(call.Args) == 0 {
+			return nil
+		}
+
+		readFile, ok := findInParentFoldersPath(call.Args[0], filepath.Dir(modulePath))
+		if !ok {
+			readFile, ok = staticTerragruntPath(call.Args[0], filepath.Dir(modulePath), filepath.Dir(path))
+		}
+		if !ok {
+			diagnostics.Warnf(path, "not tracking the file read by %s() at line %d, as its path is not static", call.Name, call.Range().Start.Line)
+			return nil
+		}
+		if !filepath.IsAbs(readFile) {
+			readFile = util.JoinPath(filepath.Dir(modulePath), readFile)
+		}
+		readFiles = append(readFiles, filepath.ToSlash(readFile))
+		return nil
+	})
+
+	return readFiles
+}
+
+// Evaluates `find_in_parent_folders("account.hcl")` like Terragrunt, looking for the file in the directories above
+// terragruntDir. Returns false for other expressions and for files that are not found.
+func findInParentFoldersPath(expr hclsyntax.Expression, terragruntDir string) (string, bool) {
+	call, ok := expr.(*hclsyntax.FunctionCallExpr)
+	if !ok || call.Name != "find_in_parent_folders" || len(call.Args) != 1 {
+		return "", false
+	}
+	name, ok := staticTerragruntPath(call.Args[0], terragruntDir, terragruntDir)
+	if !ok || filepath.IsAbs(name) {
+		return "", false
+	}
+
+	for dir := filepath.Dir(terragruntDir); ; dir = filepath.Dir(dir) {
+		if util.FileExists(filepath.Join(dir, name)) {
+			return filepath.Join(dir, name), true
+		}
+		if dir == filepath.Dir(dir) {
+			return "", false
+		}
+	}
+}
+
+// Evaluates a path like `"${get_parent_terragrunt_dir()}/backend.tf.tpl"` that is only made of string literals,
+// `get_terragrunt_dir()` and `get_parent_terragrunt_dir()`. Returns false for paths depending on anything else.
+func staticTerragruntPath(expr hclsyntax.Expression, terragruntDir string, parentTerragruntDir string) (string, bool) {
+	parts := []hclsyntax.Expression{expr}
+	switch expr := expr.(type) {
+	case *hclsyntax.TemplateExpr:
+		parts = expr.Parts
+	case *hclsyntax.TemplateWrapExpr:
+		parts = []hclsyntax.Expression{expr.Wrapped}
+	}
+
+	var path strings.Builder
+	for _, part := range parts {
+		switch part := part.(type) {
+		case *hclsyntax.LiteralValueExpr:
+			if part.Val.Type() != cty.String || !part.Val.IsKnown() || part.Val.IsNull() {
+				return "", false
+			}
+			path.WriteString(part.Val.AsString())
+		case *hclsyntax.FunctionCallExpr:
+			if len(part.Args) > 0 {
+				return "", false
+			}
+			switch part.Name {
+			case "get_terragrunt_dir":
+				path.WriteString(filepath.ToSlash(terragruntDir))
+			case "get_parent_terragrunt_dir":
+				path.WriteString(filepath.ToSlash(parentTerragruntDir))
+			default:
+				return "", false
+			}
+		default:
+			return "", false
+		}
+	}
+
+	return path.String(), path.Len() > 0
+}
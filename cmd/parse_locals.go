@@ -5,12 +5,14 @@ package cmd
 // parses the `locals` blocks and evaluates their contents.
 
 import (
+	"encoding/json"
 	"fmt"
 	"github.com/gruntwork-io/go-commons/errors"
 	"github.com/gruntwork-io/terragrunt/config"
 	"github.com/gruntwork-io/terragrunt/config/hclparse"
 	"github.com/hashicorp/hcl/v2"
 	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
 	"path/filepath"
 )
 
@@ -22,9 +24,15 @@ type ResolvedLocals struct {
 	// Apply requirements to override the global `--apply-requirements` flag
 	ApplyRequirements []string
 
+	// Plan requirements to override the global `--plan-requirements` flag
+	PlanRequirements []string
+
 	// Extra dependencies that can be hardcoded in config
 	ExtraAtlantisDependencies []string
 
+	// Tags added to the `--default-tags` of a project
+	Tags []string
+
 	// If set, a single module will have autoplan turned to this setting
 	AutoPlan *bool
 
@@ -34,8 +42,35 @@ type ResolvedLocals struct {
 	// Terraform version to use just for this project
 	TerraformVersion string
 
+	// Repo locks mode to override the global `--repo-locks-mode` flag
+	RepoLocksMode string
+
 	// If set to true, create Atlantis project
 	markedProject *bool
+
+	// If set, used as the execution order group of the project instead of computing it from its dependencies
+	ExecutionOrderGroup *int
+
+	// If set, the project never shares an execution order group with projects of other isolation groups
+	IsolationGroup string
+
+	// Name of the project, used instead of the one generated from its dir
+	ProjectName string
+
+	// Human readable description of the project, written as a comment with `--emit-descriptions`
+	Description string
+
+	// Inline workflow definition, registered as a workflow used only by this project
+	WorkflowSteps interface{}
+
+	// All locals with string values, made available to `--workspace-template`
+	StringLocals map[string]string
+
+	// Repo level automerge setting, only used from `--repo-config-hcl`
+	AutoMerge *bool
+
+	// Repo level parallel plan and apply setting, only used from `--repo-config-hcl`
+	Parallel *bool
 }
 
 // parseHcl uses the HCL2 parser to parse the given string into an HCL file body.
@@ -75,6 +110,10 @@ func mergeResolvedLocals(parent ResolvedLocals, child ResolvedLocals) ResolvedLo
 		parent.TerraformVersion = child.TerraformVersion
 	}
 
+	if child.RepoLocksMode != "" {
+		parent.RepoLocksMode = child.RepoLocksMode
+	}
+
 	if child.AutoPlan != nil {
 		parent.AutoPlan = child.AutoPlan
 	}
@@ -87,11 +126,55 @@ func mergeResolvedLocals(parent ResolvedLocals, child ResolvedLocals) ResolvedLo
 		parent.markedProject = child.markedProject
 	}
 
+	if child.ExecutionOrderGroup != nil {
+		parent.ExecutionOrderGroup = child.ExecutionOrderGroup
+	}
+
+	if child.IsolationGroup != "" {
+		parent.IsolationGroup = child.IsolationGroup
+	}
+
+	if child.ProjectName != "" {
+		parent.ProjectName = child.ProjectName
+	}
+
+	if child.Description != "" {
+		parent.Description = child.Description
+	}
+
+	for name, value := range child.StringLocals {
+		if parent.StringLocals == nil {
+			parent.StringLocals = map[string]string{}
+		}
+		parent.StringLocals[name] = value
+	}
+
+	if child.WorkflowSteps != nil {
+		parent.WorkflowSteps = child.WorkflowSteps
+	}
+
+	if child.AutoMerge != nil {
+		parent.AutoMerge = child.AutoMerge
+	}
+
+	if child.Parallel != nil {
+		parent.Parallel = child.Parallel
+	}
+
 	if child.ApplyRequirements != nil || len(child.ApplyRequirements) > 0 {
 		parent.ApplyRequirements = child.ApplyRequirements
 	}
 
-	parent.ExtraAtlantisDependencies = append(parent.ExtraAtlantisDependencies, child.ExtraAtlantisDependencies...)
+	if child.PlanRequirements != nil {
+		parent.PlanRequirements = child.PlanRequirements
+	}
+
+	if child.Tags != nil {
+		parent.Tags = child.Tags
+	}
+
+	// Dependencies declared by both the parent and the child are only kept once, where the parent declared them
+	parent.ExtraAtlantisDependencies = sliceUnion(parent.ExtraAtlantisDependencies, child.ExtraAtlantisDependencies)
 
 	return parent
 }
@@ -113,6 +196,11 @@ func parseLocals(ctx *config.ParsingContext, path string, includeFromChild *conf
 	mergedParentLocals := ResolvedLocals{}
 	if baseBlocks.TrackInclude != nil && includeFromChild == nil {
 		for _, includeConfig := range baseBlocks.TrackInclude.CurrentList {
+			// Like in Terragrunt, relative include paths are relative to the including config, for bare and labeled
+			// includes alike
+			if !filepath.IsAbs(includeConfig.Path) {
+				includeConfig.Path = filepath.Join(filepath.Dir(path), includeConfig.Path)
+			}
 			parentLocals, _ := parseLocals(ctx, includeConfig.Path, &includeConfig)
 			mergedParentLocals = mergeResolvedLocals(mergedParentLocals, parentLocals)
 		}
@@ -121,6 +209,16 @@ func parseLocals(ctx *config.ParsingContext, path string, includeFromChild *conf
 	if err != nil {
 		return ResolvedLocals{}, err
 	}
+	// Relative dependencies are relative to the child module, unless `--relativize-extra-dependencies` rebases the ones
+	// declared by an included config on its own dir
+	if includeFromChild != nil && relativizeExtraDependencies {
+		for i, dependency := range childLocals.ExtraAtlantisDependencies {
+			negation, dependency := splitNegation(dependency)
+			if !filepath.IsAbs(dependency) {
+				childLocals.ExtraAtlantisDependencies[i] = negation + filepath.ToSlash(filepath.Join(filepath.Dir(path), dependency))
+			}
+		}
+	}
 	return mergeResolvedLocals(mergedParentLocals, childLocals), nil
 }
 
@@ -143,6 +241,11 @@ func resolveLocals(localsAsCty cty.Value) (ResolvedLocals, error) {
 		resolved.TerraformVersion = versionValue.AsString()
 	}
 
+	repoLocksModeValue, ok := rawLocals["atlantis_repo_locks_mode"]
+	if ok {
+		resolved.RepoLocksMode = repoLocksModeValue.AsString()
+	}
+
 	autoPlanValue, ok := rawLocals["atlantis_autoplan"]
 	if ok {
 		hasValue := autoPlanValue.True()
@@ -165,12 +268,96 @@ func resolveLocals(localsAsCty cty.Value) (ResolvedLocals, error) {
 		}
 	}
 
+	planReqs, ok := rawLocals["atlantis_plan_requirements"]
+	if ok {
+		resolved.PlanRequirements = []string{}
+		it := planReqs.ElementIterator()
+		for it.Next() {
+			_, val := it.Element()
+			resolved.PlanRequirements = append(resolved.PlanRequirements, val.AsString())
+		}
+	}
+
+	tagsValue, ok := rawLocals["atlantis_tags"]
+	if ok {
+		resolved.Tags = []string{}
+		it := tagsValue.ElementIterator()
+		for it.Next() {
+			_, val := it.Element()
+			resolved.Tags = append(resolved.Tags, val.AsString())
+		}
+	}
+
+	for name, value := range rawLocals {
+		if value.IsKnown() && !value.IsNull() && value.Type().Equals(cty.String) {
+			if resolved.StringLocals == nil {
+				resolved.StringLocals = map[string]string{}
+			}
+			resolved.StringLocals[name] = value.AsString()
+		}
+	}
+
+	projectNameValue, ok := rawLocals["atlantis_project_name"]
+	if ok {
+		if !projectNameValue.Type().Equals(cty.String) {
+			return resolved, fmt.Errorf("atlantis_project_name must be a string")
+		}
+		resolved.ProjectName = projectNameValue.AsString()
+	}
+
+	descriptionValue, ok := rawLocals["atlantis_description"]
+	if ok {
+		resolved.Description = descriptionValue.AsString()
+	}
+
+	workflowStepsValue, ok := rawLocals["atlantis_workflow_steps"]
+	if ok {
+		// Round trip through JSON to get plain maps and slices that can be written to the YAML output
+		workflowStepsJSON, err := ctyjson.Marshal(workflowStepsValue, workflowStepsValue.Type())
+		if err != nil {
+			return resolved, err
+		}
+		if err := json.Unmarshal(workflowStepsJSON, &resolved.WorkflowSteps); err != nil {
+			return resolved, err
+		}
+	}
+
+	autoMergeValue, ok := rawLocals["atlantis_automerge"]
+	if ok {
+		hasValue := autoMergeValue.True()
+		resolved.AutoMerge = &hasValue
+	}
+
+	parallelValue, ok := rawLocals["atlantis_parallel"]
+	if ok {
+		hasValue := parallelValue.True()
+		resolved.Parallel = &hasValue
+	}
+
 	markedProject, ok := rawLocals["atlantis_project"]
 	if ok {
 		hasValue := markedProject.True()
 		resolved.markedProject = &hasValue
 	}
 
+	executionOrderGroupValue, ok := rawLocals["atlantis_execution_order_group"]
+	if ok {
+		if !executionOrderGroupValue.Type().Equals(cty.Number) {
+			return resolved, fmt.Errorf("atlantis_execution_order_group must be a number")
+		}
+		executionOrderGroup64, _ := executionOrderGroupValue.AsBigFloat().Int64()
+		executionOrderGroup := int(executionOrderGroup64)
+		resolved.ExecutionOrderGroup = &executionOrderGroup
+	}
+
+	isolationGroupValue, ok := rawLocals["atlantis_isolation_group"]
+	if ok {
+		if !isolationGroupValue.Type().Equals(cty.String) {
+			return resolved, fmt.Errorf("atlantis_isolation_group must be a string")
+		}
+		resolved.IsolationGroup = isolationGroupValue.AsString()
+	}
+
 	extraDependenciesAsCty, ok := rawLocals["extra_atlantis_dependencies"]
 	if ok {
 		it := extraDependenciesAsCty.ElementIterator()
@@ -1,11 +1,24 @@
 package cmd
 
 import (
-	"errors"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/gruntwork-io/terragrunt/terraform"
 	"github.com/gruntwork-io/terragrunt/util"
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclparse"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/terraform-config-inspect/tfconfig"
+	"github.com/zclconf/go-cty/cty"
 )
 
 var localModuleSourcePrefixes = []string{
@@ -15,23 +28,93 @@ var localModuleSourcePrefixes = []string{
 	"..\\",
 }
 
+// Module sources using the `file://` scheme always point at the local filesystem
+const fileModuleSourcePrefix = "file://"
+
+// Matches Terraform registry addresses (`hostname/namespace/name/provider`), optionally selecting a submodule with
+// the `//modules/x` syntax and pinning a version with a query string
+var registryModuleSourceRegex = regexp.MustCompile(`^[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)+/[0-9A-Za-z_-]+/[0-9A-Za-z_-]+/[0-9a-z]+(//[^?]*)?(\?.*)?$`)
+
+// Terraform functions whose first argument is the path of a file they read
+var fileReadingFunctions = map[string]bool{
+	"file":         true,
+	"templatefile": true,
+}
+
+// OpenTofu also reads `.tofu` and `.tofu.json` files, which the `*.tf*` globs don't match
+const tofuFilesGlob = "*.tofu*"
+
+// Checks if a module dir has any OpenTofu files, which need their own glob in when_modified
+func hasTofuFiles(dir string) bool {
+	tofuFiles, _ := filepath.Glob(filepath.Join(dir, tofuFilesGlob))
+	return len(tofuFiles) > 0
+}
+
 func parseTerraformLocalModuleSource(path string) ([]string, error) {
-	module, diags := tfconfig.LoadModule(path)
-	// modules, diags := parser.loadConfigDir(path)
-	if diags.HasErrors() {
-		return nil, errors.New(diags.Error())
+	// Only the configuration files, as `*.tf*` also matches state files which can be large without being parsed
+	moduleFiles, err := findModuleFiles(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	tfFiles, tofuFiles := []string{}, []string{}
+	for _, moduleFile := range moduleFiles {
+		if strings.HasSuffix(moduleFile, ".tofu") || strings.HasSuffix(moduleFile, ".tofu.json") {
+			tofuFiles = append(tofuFiles, moduleFile)
+		} else {
+			tfFiles = append(tfFiles, moduleFile)
+		}
+	}
+	// Modules of only OpenTofu files are not read by tfconfig, which would find nothing in them
+	tofuOnly := len(tfFiles) == 0 && len(tofuFiles) > 0
+	tfFiles = append(tfFiles, tofuFiles...)
+	// Skip modules with oversized files, the module itself is still tracked by the caller's `*.tf*` glob
+	for _, tfFile := range tfFiles {
+		if exceedsMaxFileSize(tfFile) {
+			diagnostics.Warnf(tfFile, "not parsing local module sources of %s as the file is larger than --max-file-size of %d bytes", path, maxFileSize)
+			return []string{}, nil
+		}
+	}
+
+	// Modules shared by many parents are only parsed once, as long as their files don't change
+	cacheKey, err := moduleSourcesCacheKey(path, tfFiles)
+	if err != nil {
+		return nil, err
+	}
+	if sources, ok := moduleSourcesCache.get(cacheKey); ok {
+		return sources, nil
+	}
+
+	moduleCallSources, err := loadModuleCallSources(path, !tofuOnly, tofuFiles)
+	if err != nil {
+		if !ignoreTfParseErrors {
+			return nil, err
+		}
+
+		// The callers already track the module's own `*.tf*` files, only the modules it calls are lost
+		diagnostics.Warnf(path, "%v. Only changes to the module's own files will trigger plans", err)
+		moduleSourcesCache.set(cacheKey, []string{})
+		return []string{}, nil
 	}
 
 	var sourceMap = map[string]bool{}
-	for _, mc := range module.ModuleCalls {
-		if isLocalTerraformModuleSource(mc.Source) {
-			modulePath := util.JoinPath(path, mc.Source)
+	for _, readFile := range findReadFiles(path, tfFiles) {
+		sourceMap[readFile] = true
+	}
+	for _, moduleCallSource := range moduleCallSources {
+		if source, ok := localTerraformModuleSourcePath(moduleCallSource); ok {
+			modulePath := source
+			if !filepath.IsAbs(modulePath) {
+				modulePath = util.JoinPath(path, source)
+			}
 			modulePathGlob := util.JoinPath(modulePath, "*.tf*")
 
 			if _, exists := sourceMap[modulePathGlob]; exists {
 				continue
 			}
 			sourceMap[modulePathGlob] = true
+			if hasTofuFiles(modulePath) {
+				sourceMap[util.JoinPath(modulePath, tofuFilesGlob)] = true
+			}
 
 			// find local module source recursively
 			subSources, err := parseTerraformLocalModuleSource(modulePath)
@@ -50,15 +133,388 @@ func parseTerraformLocalModuleSource(path string) ([]string, error) {
 		sources = append(sources, source)
 	}
 
+	moduleSourcesCache.set(cacheKey, sources)
 	return sources, nil
 }
 
-func isLocalTerraformModuleSource(raw string) bool {
-	for _, prefix := range localModuleSourcePrefixes {
-		if strings.HasPrefix(raw, prefix) {
+// Returns the sources of the module calls of a module. tfconfig only reads `.tf` and `.tf.json` files, so the `module`
+// blocks of the `.tofu` and `.tofu.json` files are found separately.
+func loadModuleCallSources(path string, useTfconfig bool, tofuFiles []string) ([]string, error) {
+	if !useTfconfig {
+		return parseModuleCallSources(path, tofuFiles)
+	}
+
+	// tfconfig rejects syntax only OpenTofu accepts, so such modules go straight to the hcl parser
+	moduleFiles, err := findModuleFiles(path)
+	if err != nil {
+		return nil, err
+	}
+	if hasOpenTofuProviderSyntax(moduleFiles) {
+		return parseModuleCallSources(path, moduleFiles)
+	}
+
+	module, diags := tfconfig.LoadModule(path)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse local module %s%s: %w", path, firstErrorPosition(diags), diags.Err())
+	}
+
+	sources := []string{}
+	for _, mc := range module.ModuleCalls {
+		sources = append(sources, mc.Source)
+	}
+	tofuSources, err := parseModuleCallSources(path, tofuFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(sources, tofuSources...), nil
+}
+
+// Returns the sources of the module blocks of the given files, without evaluating anything but the sources
+func parseModuleCallSources(path string, files []string) ([]string, error) {
+	sources := []string{}
+	parser := hclparse.NewParser()
+	for _, moduleFile := range files {
+		var file *hcl.File
+		var diags hcl.Diagnostics
+		if strings.HasSuffix(moduleFile, ".json") {
+			file, diags = parser.ParseJSONFile(moduleFile)
+		} else {
+			file, diags = parser.ParseHCLFile(moduleFile)
+		}
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to parse local module %s: %w", path, diags)
+		}
+
+		content, _, diags := file.Body.PartialContent(&hcl.BodySchema{
+			Blocks: []hcl.BlockHeaderSchema{{Type: "module", LabelNames: []string{"name"}}},
+		})
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("failed to parse local module %s: %w", path, diags)
+		}
+		for _, block := range content.Blocks {
+			attributes, _, _ := block.Body.PartialContent(&hcl.BodySchema{
+				Attributes: []hcl.AttributeSchema{{Name: "source"}},
+			})
+			attribute, ok := attributes.Attributes["source"]
+			if !ok {
+				continue
+			}
+			// Like for tfconfig, sources must be plain strings
+			value, diags := attribute.Expr.Value(nil)
+			if diags.HasErrors() || value.Type() != cty.String || value.IsNull() {
+				continue
+			}
+			sources = append(sources, value.AsString())
+		}
+	}
+
+	return sources, nil
+}
+
+// The files of a module dir holding its configuration, leaving out the `.tfvars` and state files `*.tf*` also matches
+var moduleFileSuffixes = []string{".tf", ".tf.json", ".tofu", ".tofu.json"}
+
+func findModuleFiles(path string) ([]string, error) {
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+
+	moduleFiles := []string{}
+	for _, entry := range entries {
+		if !entry.IsDir() && isModuleFile(entry.Name()) {
+			moduleFiles = append(moduleFiles, filepath.Join(path, entry.Name()))
+		}
+	}
+
+	return moduleFiles, nil
+}
+
+func isModuleFile(name string) bool {
+	for _, suffix := range moduleFileSuffixes {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Checks if any `provider` argument of the given files is an OpenTofu provider reference. Files that don't parse are
+// left for tfconfig to report.
+func hasOpenTofuProviderSyntax(files []string) bool {
+	for _, filename := range files {
+		if strings.HasSuffix(filename, ".json") {
+			continue
+		}
+		src, err := os.ReadFile(filename)
+		if err != nil {
+			continue
+		}
+		file, diags := hclsyntax.ParseConfig(src, filename, hcl.Pos{Line: 1, Column: 1})
+		if diags.HasErrors() {
+			continue
+		}
+
+		found := false
+		hclsyntax.VisitAll(file.Body.(*hclsyntax.Body), func(node hclsyntax.Node) hcl.Diagnostics {
+			if attribute, ok := node.(*hclsyntax.Attribute); ok && attribute.Name == "provider" {
+				found = found || isOpenTofuProviderSyntax(attribute.Expr)
+			}
+			return nil
+		})
+		if found {
 			return true
 		}
 	}
 
 	return false
 }
+
+// OpenTofu lets `provider` select one instance of a provider configured with `for_each`, like `aws.by_region[each.key]`.
+// Terraform only accepts a provider name followed by an optional alias.
+func isOpenTofuProviderSyntax(expr hclsyntax.Expression) bool {
+	switch expr := expr.(type) {
+	case *hclsyntax.IndexExpr:
+		collection, ok := expr.Collection.(*hclsyntax.ScopeTraversalExpr)
+		return ok && len(collection.Traversal) == 2
+	case *hclsyntax.ScopeTraversalExpr:
+		if len(expr.Traversal) != 3 {
+			return false
+		}
+		_, ok := expr.Traversal[2].(hcl.TraverseIndex)
+		return ok
+	}
+
+	return false
+}
+
+// Caches the local module sources found below a module dir, as parsing the same shared submodule for every module
+// calling it dominates generation time in repos with many modules
+type ModuleSourcesCache struct {
+	mtx  sync.RWMutex
+	data map[string][]string
+}
+
+func newModuleSourcesCache() *ModuleSourcesCache {
+	return &ModuleSourcesCache{data: map[string][]string{}}
+}
+
+// Callers sort the sources in place, so both directions copy them
+func (m *ModuleSourcesCache) set(k string, v []string) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	m.data[k] = append([]string{}, v...)
+}
+
+func (m *ModuleSourcesCache) get(k string) ([]string, bool) {
+	m.mtx.RLock()
+	defer m.mtx.RUnlock()
+	v, ok := m.data[k]
+	if !ok {
+		return nil, false
+	}
+	return append([]string{}, v...), true
+}
+
+var moduleSourcesCache = newModuleSourcesCache()
+
+// Keys the cache by the absolute module dir and a hash of its Terraform files, so edited modules are parsed again
+func moduleSourcesCacheKey(path string, tfFiles []string) (string, error) {
+	absolutePath, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.New()
+	for _, tfFile := range tfFiles {
+		// Entries that can't be read, like directories matching the glob, are left for tfconfig to report
+		contents, err := os.ReadFile(tfFile)
+		if err != nil {
+			continue
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", filepath.Base(tfFile), len(contents))
+		hash.Write(contents)
+	}
+
+	return absolutePath + "@" + hex.EncodeToString(hash.Sum(nil)) + "@" + dependencyOptionsFingerprint(), nil
+}
+
+// Finds the files read with `file()` and `templatefile()` in the `.tf` files of a module, so changes to templates
+// trigger plans as well. Only paths made of string literals and `path.module` can be resolved, other calls are skipped
+// with a warning.
+func findReadFiles(path string, tfFiles []string) []string {
+	readFiles := []string{}
+	for _, tfFile := range tfFiles {
+		if ext := filepath.Ext(tfFile); ext != ".tf" && ext != ".tofu" {
+			continue
+		}
+		contents, err := os.ReadFile(tfFile)
+		if err != nil {
+			continue
+		}
+		// tfconfig already parsed the module successfully, so errors here are not expected
+		file, diags := hclsyntax.ParseConfig(contents, tfFile, hcl.InitialPos)
+		if diags.HasErrors() {
+			continue
+		}
+
+		hclsyntax.VisitAll(file.Body.(*hclsyntax.Body), func(node hclsyntax.Node) hcl.Diagnostics {
+			call, ok := node.(*hclsyntax.FunctionCallExpr)
+			if !ok || !fileReadingFunctions[call.Name] || len(call.Args) == 0 {
+				return nil
+			}
+
+			readFile, ok := staticModulePath(call.Args[0])
+			if !ok {
+				diagnostics.Warnf(tfFile, "not tracking the file read by %s() at line %d, as its path is not static", call.Name, call.Range().Start.Line)
+				return nil
+			}
+			if !filepath.IsAbs(readFile) {
+				readFile = util.JoinPath(path, readFile)
+			}
+			readFiles = append(readFiles, filepath.ToSlash(readFile))
+			return nil
+		})
+	}
+
+	return readFiles
+}
+
+// Evaluates a path like `"${path.module}/config.tpl"` that is only made of string literals and `path.module`, with
+// `path.module` resolving to `.`. Returns false for paths depending on anything else, like variables.
+func staticModulePath(expr hclsyntax.Expression) (string, bool) {
+	template, ok := expr.(*hclsyntax.TemplateExpr)
+	if !ok {
+		return "", false
+	}
+
+	var path strings.Builder
+	for _, part := range template.Parts {
+		switch part := part.(type) {
+		case *hclsyntax.LiteralValueExpr:
+			if part.Val.Type() != cty.String || !part.Val.IsKnown() || part.Val.IsNull() {
+				return "", false
+			}
+			path.WriteString(part.Val.AsString())
+		case *hclsyntax.ScopeTraversalExpr:
+			traversal := part.Traversal
+			if len(traversal) != 2 || traversal.RootName() != "path" {
+				return "", false
+			}
+			if attr, ok := traversal[1].(hcl.TraverseAttr); !ok || attr.Name != "module" {
+				return "", false
+			}
+			path.WriteString(".")
+		default:
+			return "", false
+		}
+	}
+
+	return path.String(), path.Len() > 0
+}
+
+// Describes where the first error of diags is, like ` at modules/app/main.tf:3`, as tfconfig leaves the position out of
+// its messages. Returns an empty string if the error has no position.
+func firstErrorPosition(diags tfconfig.Diagnostics) string {
+	for _, diag := range diags {
+		if diag.Severity == tfconfig.DiagError && diag.Pos != nil {
+			return fmt.Sprintf(" at %s:%d", diag.Pos.Filename, diag.Pos.Line)
+		}
+	}
+
+	return ""
+}
+
+// localTerraformModuleSourcePath returns the filesystem path a local module source points at, and whether the
+// source is local at all. A `file://` source is local whatever follows the scheme, so `file:///abs/path`,
+// `file://../relative` and a bare `file://name` are all resolved as paths once the scheme is stripped.
+// Windows separators are converted so the emitted globs are identical on every OS, and query strings are removed.
+func localTerraformModuleSourcePath(raw string) (string, bool) {
+	raw = stripModuleSourceQuery(raw)
+	if strings.HasPrefix(raw, fileModuleSourcePrefix) {
+		path := strings.TrimPrefix(raw, fileModuleSourcePrefix)
+		return strings.ReplaceAll(path, "\\", "/"), path != ""
+	}
+
+	for _, prefix := range localModuleSourcePrefixes {
+		if strings.HasPrefix(raw, prefix) {
+			return strings.ReplaceAll(raw, "\\", "/"), true
+		}
+	}
+
+	return "", false
+}
+
+// Removes a query string like `?ref=v1.2.3` from a module source. Local paths can't be versioned, but a query left on
+// one would otherwise end up in the emitted globs.
+func stripModuleSourceQuery(raw string) string {
+	path, _, _ := strings.Cut(raw, "?")
+	return path
+}
+
+// isRegistryModuleSource checks if a source is a Terraform registry address. go-getter has no detector for these, so
+// without this check they would be mistaken for a relative path on the local filesystem.
+func isRegistryModuleSource(raw string) bool {
+	return registryModuleSourceRegex.MatchString(raw)
+}
+
+// findVendoredModuleDir looks for the copy of a remote module that Terragrunt downloaded into the `.terragrunt-cache`
+// next to a module, honoring any `//subdir` in the source. Terragrunt downloads into a dir named by a hash of the
+// absolute working dir, which differs between machines, and a hash of the source without its query string. Also
+// returns the cache path up to the working dir hash, so callers can replace it with a glob. Returns empty strings if
+// the module has not been vendored, or its copy can't be found.
+//
+// The source must already be normalized by go-getter, so Terragrunt does not run its own detectors, some of which ask
+// the network. Under `--offline`, a source they would still look up over the network is an error.
+func findVendoredModuleDir(moduleDir string, source string) (string, string, error) {
+	if err := checkTerragruntSourceOffline(source, moduleDir); err != nil {
+		return "", "", err
+	}
+
+	cacheDir := filepath.Join(moduleDir, ".terragrunt-cache")
+	terrOpts, err := options.NewTerragruntOptionsWithConfigPath(moduleDir)
+	if err != nil {
+		return "", "", nil
+	}
+	terraformSource, err := terraform.NewSource(source, cacheDir, moduleDir, terrOpts.Logger, false)
+	if err != nil {
+		return "", "", nil
+	}
+	subDir, err := filepath.Rel(terraformSource.DownloadDir, terraformSource.WorkingDir)
+	if err != nil {
+		return "", "", nil
+	}
+
+	// Copies of other sources, or of this one for another working dir, are ignored
+	downloads, err := filepath.Glob(filepath.Join(cacheDir, "*", filepath.Base(terraformSource.DownloadDir)))
+	if err != nil {
+		return "", "", nil
+	}
+	sort.Strings(downloads)
+
+	for _, download := range downloads {
+		vendoredDir := filepath.Join(download, subDir)
+		if util.IsDir(vendoredDir) {
+			return vendoredDir, filepath.Dir(download), nil
+		}
+	}
+
+	return "", "", nil
+}
+
+// Replaces the working dir hash of the `.terragrunt-cache` in the given paths by a glob, so the paths are the same
+// on every machine
+func stableVendoredPaths(paths []string, workingDirCache string) []string {
+	prefix := filepath.ToSlash(workingDirCache) + "/"
+	stablePrefix := filepath.ToSlash(filepath.Join(filepath.Dir(workingDirCache), "*")) + "/"
+
+	stablePaths := []string{}
+	for _, path := range paths {
+		if strings.HasPrefix(filepath.ToSlash(path), prefix) {
+			path = stablePrefix + strings.TrimPrefix(filepath.ToSlash(path), prefix)
+		}
+		stablePaths = append(stablePaths, path)
+	}
+	return stablePaths
+}
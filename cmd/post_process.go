@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+)
+
+// Runs the `--post-process` command with the generated config on stdin, returning the config it writes to stdout.
+// The command is run by the shell, so it can have arguments. The returned config is checked again like the generated
+// one, so a post-processor can not write a config Atlantis would refuse.
+func postProcessConfig(ctx context.Context, yamlBytes []byte) ([]byte, *AtlantisConfig, error) {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+
+	stdout := &bytes.Buffer{}
+	command := exec.CommandContext(ctx, shell, flag, postProcess)
+	command.Stdin = bytes.NewReader(yamlBytes)
+	command.Stdout = stdout
+	// Logs of the command go to stderr like ours
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		return nil, nil, fmt.Errorf("--post-process %q failed: %w", postProcess, err)
+	}
+
+	config, err := ParseAtlantisConfig(stdout.Bytes())
+	if err != nil {
+		return nil, nil, fmt.Errorf("--post-process %q did not write a valid config: %w", postProcess, err)
+	}
+	if err := checkWorkflowReferences(config); err != nil {
+		return nil, nil, fmt.Errorf("--post-process %q: %w", postProcess, err)
+	}
+	if validateOutput {
+		if err := validateAtlantisConfig(config); err != nil {
+			return nil, nil, fmt.Errorf("--post-process %q: %w", postProcess, err)
+		}
+	}
+
+	return stdout.Bytes(), config, nil
+}
@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"os"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// Starts the CPU profile and execution trace requested with `--cpuprofile` and `--trace`. The returned function stops
+// them and closes their files, and must be called before the run returns so the files are complete.
+func startProfiling() (func(), error) {
+	stops := []func(){}
+	stop := func() {
+		for i := len(stops) - 1; i >= 0; i-- {
+			stops[i]()
+		}
+	}
+
+	if cpuProfilePath != "" {
+		file, err := os.Create(cpuProfilePath)
+		if err != nil {
+			return nil, err
+		}
+		if err := pprof.StartCPUProfile(file); err != nil {
+			file.Close()
+			return nil, err
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			file.Close()
+		})
+	}
+
+	if tracePath != "" {
+		file, err := os.Create(tracePath)
+		if err != nil {
+			stop()
+			return nil, err
+		}
+		if err := trace.Start(file); err != nil {
+			file.Close()
+			stop()
+			return nil, err
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			file.Close()
+		})
+	}
+
+	return stop, nil
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/gruntwork-io/terragrunt/config"
+	"github.com/gruntwork-io/terragrunt/options"
+	"github.com/spf13/pflag"
+)
+
+// Reads the locals of the `--repo-config-hcl` file and uses them as defaults for every flag that was not explicitly
+// set, so repo wide Atlantis settings can live in the repo instead of in CLI invocations
+func applyRepoConfigHcl(ctx context.Context, flags *pflag.FlagSet) error {
+	path := repoConfigHcl
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(gitRoot, path)
+	}
+
+	repoConfigOptions, err := options.NewTerragruntOptionsWithConfigPath(path)
+	if err != nil {
+		return err
+	}
+	repoConfigOptions.Env = getEnvs()
+
+	locals, err := parseLocals(config.NewParsingContext(ctx, repoConfigOptions), path, nil)
+	if err != nil {
+		return err
+	}
+
+	if locals.AutoMerge != nil && !flags.Changed("automerge") {
+		autoMerge = *locals.AutoMerge
+	}
+
+	if locals.Parallel != nil && !flags.Changed("parallel") {
+		parallel = *locals.Parallel
+	}
+
+	if locals.AutoPlan != nil && !flags.Changed("autoplan") {
+		autoPlan = *locals.AutoPlan
+	}
+
+	if locals.AtlantisWorkflow != "" && !flags.Changed("workflow") {
+		defaultWorkflow = locals.AtlantisWorkflow
+	}
+
+	if locals.ApplyRequirements != nil && !flags.Changed("apply-requirements") && !flags.Changed("default-apply-requirements") {
+		defaultApplyRequirements = locals.ApplyRequirements
+	}
+
+	if locals.PlanRequirements != nil && !flags.Changed("plan-requirements") {
+		defaultPlanRequirements = locals.PlanRequirements
+	}
+
+	if locals.TerraformVersion != "" && !flags.Changed("terraform-version") {
+		defaultTerraformVersion = locals.TerraformVersion
+	}
+
+	if locals.RepoLocksMode != "" && !flags.Changed("repo-locks-mode") {
+		repoLocksMode = locals.RepoLocksMode
+	}
+
+	if locals.Tags != nil && !flags.Changed("default-tags") {
+		defaultTags = locals.Tags
+	}
+
+	return nil
+}
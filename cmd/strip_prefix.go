@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The dir Atlantis resolves project dirs from, which is the dir of the config file
+func configFileDir() (string, error) {
+	if outputPath == "" || outputPath == stdoutOutputPath {
+		return os.Getwd()
+	}
+	return filepath.Abs(filepath.Dir(outputPath))
+}
+
+// Removes `--strip-prefix` from the dirs of the generated projects, and with `--strip-prefix-names` from their names
+// and the depends_on naming them. A stripped dir must be the same dir relative to the config file as the dir was
+// relative to the root, or Atlantis would plan another dir, so this only helps when the dirs are made relative to a
+// dir above the repo, like with `--base-dir`.
+func stripProjectPrefix(projects []AtlantisProject) error {
+	if stripPrefix == "" {
+		return nil
+	}
+	prefix := strings.TrimSuffix(filepath.ToSlash(stripPrefix), "/") + "/"
+	// Generated names have the dir separators replaced, like in createProject
+	namePrefix := regexp.MustCompile(`[^a-zA-Z0-9_-]+`).ReplaceAllString(prefix, "_")
+
+	configDir, err := configFileDir()
+	if err != nil {
+		return err
+	}
+
+	renamed := map[string]string{}
+	for i := range projects {
+		project := &projects[i]
+		if project.preserved || !strings.HasPrefix(project.Dir+"/", prefix) {
+			continue
+		}
+
+		dir := path.Clean(strings.TrimPrefix(project.Dir+"/", prefix))
+		generatedDir, err := os.Stat(filepath.Join(gitRoot, project.Dir))
+		if err != nil {
+			return err
+		}
+		strippedDir, err := os.Stat(filepath.Join(configDir, dir))
+		if err != nil || !os.SameFile(generatedDir, strippedDir) {
+			return fmt.Errorf("project dir %s stripped by --strip-prefix is %s, which is not the same dir relative to the config in %s", project.Dir, dir, configDir)
+		}
+		project.Dir = dir
+
+		if stripPrefixNames && strings.HasPrefix(project.Name, namePrefix) {
+			name := strings.TrimPrefix(project.Name, namePrefix)
+			renamed[project.Name] = name
+			project.Name = name
+		}
+	}
+
+	for i := range projects {
+		for j, dependency := range projects[i].DependsOn {
+			if name, ok := renamed[dependency]; ok {
+				projects[i].DependsOn[j] = name
+			}
+		}
+	}
+	return nil
+}
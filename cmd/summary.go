@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gruntwork-io/terragrunt/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// Formats accepted by `--summary`
+var summaryFormats = []string{"json"}
+
+// Summary of a run, written with `--summary=json` for CI dashboards and performance tracking
+type Summary struct {
+	// Terragrunt module configs found below the roots, not counting root configs like root.hcl
+	ModulesDiscovered int64 `json:"modules_discovered"`
+
+	// Projects written to the config
+	ProjectsEmitted int `json:"projects_emitted"`
+
+	// Configs whose dependencies were parsed
+	DependenciesResolved int64 `json:"dependencies_resolved"`
+
+	// Lookups of dependencies that were served from the cache instead of parsing the config again
+	DependencyCacheHits int64 `json:"dependency_cache_hits"`
+
+	// Diagnostics collected during the run
+	Warnings int `json:"warnings"`
+
+	DurationMilliseconds int64 `json:"duration_ms"`
+}
+
+// Counters of the current run. Dependencies are resolved concurrently, so they are updated atomically.
+type runStats struct {
+	started              time.Time
+	modulesDiscovered    atomic.Int64
+	dependenciesResolved atomic.Int64
+	dependencyCacheHits  atomic.Int64
+
+	// How long parsing each module and resolving its dependencies took, only recorded with `--trace-slow-modules`
+	moduleDurationsMtx sync.Mutex
+	moduleDurations    map[string]time.Duration
+}
+
+// Records how long creating the project of the module at path took since started
+func (s *runStats) recordModuleDuration(path string, started time.Time) {
+	duration := time.Since(started)
+
+	s.moduleDurationsMtx.Lock()
+	defer s.moduleDurationsMtx.Unlock()
+	if s.moduleDurations == nil {
+		s.moduleDurations = map[string]time.Duration{}
+	}
+	s.moduleDurations[path] = duration
+}
+
+// Logs the `--trace-slow-modules` slowest modules, slowest first
+func logSlowModules() {
+	stats.moduleDurationsMtx.Lock()
+	defer stats.moduleDurationsMtx.Unlock()
+
+	paths := []string{}
+	for path := range stats.moduleDurations {
+		paths = append(paths, path)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		if stats.moduleDurations[paths[i]] != stats.moduleDurations[paths[j]] {
+			return stats.moduleDurations[paths[i]] > stats.moduleDurations[paths[j]]
+		}
+		return paths[i] < paths[j]
+	})
+	if len(paths) > traceSlowModules {
+		paths = paths[:traceSlowModules]
+	}
+
+	log.Infof("The %d slowest of %d modules to parse:", len(paths), len(stats.moduleDurations))
+	for _, path := range paths {
+		log.Infof("  %s %s", stats.moduleDurations[path].Round(time.Millisecond), path)
+	}
+}
+
+// Stats of the current run
+var stats = &runStats{started: time.Now()}
+
+// Checks that `--summary` names a known format
+func validateSummary() error {
+	if summaryFormat != "" && !util.ListContainsElement(summaryFormats, summaryFormat) {
+		return fmt.Errorf("unknown --summary value %q, must be one of %s", summaryFormat, strings.Join(summaryFormats, ", "))
+	}
+
+	return nil
+}
+
+// Writes the summary of the run to w, which is stderr outside of tests so the config on stdout stays untouched
+func writeSummary(w io.Writer, config *AtlantisConfig) error {
+	summary := Summary{
+		ModulesDiscovered:    stats.modulesDiscovered.Load(),
+		ProjectsEmitted:      len(config.Projects),
+		DependenciesResolved: stats.dependenciesResolved.Load(),
+		DependencyCacheHits:  stats.dependencyCacheHits.Load(),
+		Warnings:             len(diagnostics.All()),
+		DurationMilliseconds: time.Since(stats.started).Milliseconds(),
+	}
+
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(summaryJSON))
+	return err
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/gruntwork-io/terragrunt/util"
+)
+
+// Requirements Atlantis accepts in `apply_requirements` and `plan_requirements`
+var validRequirements = []string{"approved", "mergeable", "undiverged"}
+
+// Checks that every project workflow is defined in `workflows`, either generated or preserved, unless
+// `--allow-undefined-workflows` says they are defined in the server side repo config. The `default` workflow is
+// always defined.
+func checkWorkflowReferences(config *AtlantisConfig) error {
+	if allowUndefinedWorkflows {
+		return nil
+	}
+
+	workflows := map[string]bool{"default": true}
+	if definedWorkflows, ok := config.Workflows.(map[string]interface{}); ok {
+		for name := range definedWorkflows {
+			workflows[name] = true
+		}
+	}
+
+	missing := []string{}
+	for _, project := range config.Projects {
+		if project.Workflow != "" && !workflows[project.Workflow] {
+			missing = append(missing, project.Workflow)
+		}
+	}
+	if len(missing) > 0 {
+		missing = uniqueStrings(missing)
+		sort.Strings(missing)
+		return fmt.Errorf("projects use workflows that are not defined in workflows: %s. Use --allow-undefined-workflows if they are defined on the Atlantis server", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// Checks the generated config against the rules Atlantis applies when loading a version 3 repo config, so a config
+// Atlantis refuses to load is never written. Workflow references are checked by checkWorkflowReferences.
+func validateAtlantisConfig(config *AtlantisConfig) error {
+	problems := []string{}
+	if config.Version != 3 {
+		problems = append(problems, fmt.Sprintf("version must be 3, not %d", config.Version))
+	}
+	if _, ok := config.Workflows.(map[string]interface{}); config.Workflows != nil && !ok {
+		problems = append(problems, "workflows must be a map of workflow names to workflows")
+	}
+
+	names := map[string]bool{}
+	for _, project := range config.Projects {
+		if project.Name != "" {
+			if names[project.Name] {
+				problems = append(problems, fmt.Sprintf("project name %q is used by more than one project", project.Name))
+			}
+			names[project.Name] = true
+		}
+	}
+
+	// Unnamed projects are told apart by their dir and workspace
+	unnamedProjects := map[string]bool{}
+	for _, project := range config.Projects {
+		if project.Dir == "" {
+			problems = append(problems, "a project has no dir")
+			continue
+		}
+		if path.IsAbs(project.Dir) || project.Dir == ".." || strings.HasPrefix(project.Dir, "../") || strings.Contains(project.Dir, "/../") {
+			problems = append(problems, fmt.Sprintf("project dir %s must be relative to the repo root and must not contain ..", project.Dir))
+		}
+
+		if project.Name == "" {
+			workspace := project.Workspace
+			if workspace == "" {
+				workspace = "default"
+			}
+			key := project.Dir + " " + workspace
+			if unnamedProjects[key] {
+				problems = append(problems, fmt.Sprintf("there are several projects with dir %s and workspace %s, they must have a name", project.Dir, workspace))
+			}
+			unnamedProjects[key] = true
+		}
+
+		for _, requirements := range []*[]string{project.ApplyRequirements, project.PlanRequirements} {
+			if requirements == nil {
+				continue
+			}
+			for _, requirement := range *requirements {
+				if !util.ListContainsElement(validRequirements, requirement) {
+					problems = append(problems, fmt.Sprintf("project %s has requirement %q, which must be one of %s", project.Dir, requirement, strings.Join(validRequirements, ", ")))
+				}
+			}
+		}
+
+		for _, dependency := range project.DependsOn {
+			if !names[dependency] {
+				problems = append(problems, fmt.Sprintf("project %s depends on project %q, which does not exist", project.Dir, dependency))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("the generated config would be rejected by Atlantis:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// The values available to `--workspace-template`
+type workspaceTemplateData struct {
+	// The project dir, relative to the root
+	Dir string
+
+	// The parts of the project dir, so `{{ index .Segments 0 }}` is its first directory
+	Segments []string
+
+	// String locals of the module, including those inherited from included parents
+	Locals map[string]string
+}
+
+// The parsed `--workspace-template`, nil when it is not set
+var workspaceTemplate *template.Template
+
+// Parses `--workspace-template`, so mistakes are reported before any module is parsed
+func parseWorkspaceTemplate() error {
+	workspaceTemplate = nil
+	if workspaceTemplateText == "" {
+		return nil
+	}
+
+	parsed, err := template.New("workspace").Option("missingkey=error").Parse(workspaceTemplateText)
+	if err != nil {
+		return fmt.Errorf("invalid --workspace-template: %w", err)
+	}
+
+	workspaceTemplate = parsed
+	return nil
+}
+
+// Renders `--workspace-template` for the project in dir. Characters that are not allowed in workspace names are
+// replaced the same way as for `--create-workspace`.
+func renderWorkspace(dir string, locals ResolvedLocals) (string, error) {
+	data := workspaceTemplateData{
+		Dir:      dir,
+		Segments: strings.Split(dir, "/"),
+		Locals:   locals.StringLocals,
+	}
+	if data.Locals == nil {
+		data.Locals = map[string]string{}
+	}
+
+	var rendered bytes.Buffer
+	if err := workspaceTemplate.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("rendering --workspace-template for %s: %w", dir, err)
+	}
+
+	regex := regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+	return regex.ReplaceAllString(rendered.String(), "_"), nil
+}
@@ -0,0 +1,17 @@
+locals {
+  region = "us-east-1"
+}
+
+generate "provider" {
+  path      = "provider.tf"
+  if_exists = "overwrite_terragrunt"
+  contents  = <<EOT
+provider "aws" {
+  region = "${local.region}"
+}
+EOT
+}
+
+inputs = {
+  region = local.region
+}
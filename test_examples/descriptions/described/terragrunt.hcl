@@ -0,0 +1,7 @@
+locals {
+  atlantis_description = "Shared VPC for all environments.\nApplied by the network team."
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
@@ -0,0 +1,20 @@
+include {
+  path = find_in_parent_folders()
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+locals {
+  # The same dependencies as in the parent, only the last one is new
+  extra_atlantis_dependencies = [
+    "shared.yaml",
+    find_in_parent_folders("common.json"),
+    "child_only.yaml",
+  ]
+}
+
+inputs = {
+  foo = "bar"
+}
@@ -0,0 +1,6 @@
+locals {
+  extra_atlantis_dependencies = [
+    "shared.yaml",
+    "${get_parent_terragrunt_dir()}/common.json",
+  ]
+}
@@ -0,0 +1,3 @@
+locals {
+  environment = "with_modules"
+}
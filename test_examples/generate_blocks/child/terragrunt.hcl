@@ -0,0 +1,18 @@
+include {
+  path = find_in_parent_folders()
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+# Relative paths are relative to this module, also in the generate blocks of the parent
+generate "versions" {
+  path      = "versions.tf"
+  if_exists = "overwrite_terragrunt"
+  contents  = file("generated/versions.tpl")
+}
+
+inputs = {
+  foo = "bar"
+}
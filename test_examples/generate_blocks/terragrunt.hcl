@@ -0,0 +1,13 @@
+generate "backend" {
+  path      = "backend.tf"
+  if_exists = "overwrite_terragrunt"
+  contents  = file("${get_parent_terragrunt_dir()}/templates/backend.tf.tpl")
+}
+
+generate "provider" {
+  path      = "provider.tf"
+  if_exists = "overwrite_terragrunt"
+  contents  = templatefile("${get_parent_terragrunt_dir()}/templates/provider.tf.tpl", {
+    region = "us-east-1"
+  })
+}
@@ -0,0 +1,39 @@
+# No include, no terraform source and no terraform files. Terragrunt runs the module generated below in this dir, with
+# its backend and provider.
+generate "main" {
+  path      = "main.tf"
+  if_exists = "overwrite_terragrunt"
+  contents  = <<EOF
+variable "name" {}
+
+resource "null_resource" "this" {
+  triggers = {
+    name = var.name
+  }
+}
+EOF
+}
+
+generate "backend" {
+  path      = "backend.tf"
+  if_exists = "overwrite_terragrunt"
+  contents  = <<EOF
+terraform {
+  backend "s3" {}
+}
+EOF
+}
+
+generate "provider" {
+  path      = "provider.tf"
+  if_exists = "overwrite_terragrunt"
+  contents  = <<EOF
+provider "aws" {
+  region = "us-east-1"
+}
+EOF
+}
+
+inputs = {
+  name = "generated"
+}
@@ -0,0 +1,9 @@
+# Shared by every environment deploying the app. Relative sources are resolved from the including child's directory,
+# so the source is built from the directory of this file instead.
+locals {
+  module_dir = "${get_parent_terragrunt_dir()}/../modules/app"
+}
+
+terraform {
+  source = local.module_dir
+}
@@ -0,0 +1,7 @@
+include "app" {
+  path = "../../_envcommon/app.hcl"
+}
+
+inputs = {
+  name = "prod"
+}
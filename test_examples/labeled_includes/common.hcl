@@ -0,0 +1,3 @@
+locals {
+  atlantis_terraform_version = "1.5.0"
+}
@@ -0,0 +1,11 @@
+module "module_1" {
+  source = "file://../terraform-module"
+}
+
+module "module_2" {
+  source = "file://./submodule"
+}
+
+module "module_3" {
+  source = "file://bare-module"
+}
@@ -0,0 +1,7 @@
+terraform {
+  source = "../root-module"
+}
+
+inputs = {
+  foo = "bar"
+}
@@ -0,0 +1,7 @@
+module "module_1" {
+  source = "..\\terraform-module"
+}
+
+module "module_2" {
+  source = ".\\nested\\submodule"
+}
@@ -0,0 +1,34 @@
+# Padding line 000 to push this file over the --max-file-size used in tests
+# Padding line 001 to push this file over the --max-file-size used in tests
+# Padding line 002 to push this file over the --max-file-size used in tests
+# Padding line 003 to push this file over the --max-file-size used in tests
+# Padding line 004 to push this file over the --max-file-size used in tests
+# Padding line 005 to push this file over the --max-file-size used in tests
+# Padding line 006 to push this file over the --max-file-size used in tests
+# Padding line 007 to push this file over the --max-file-size used in tests
+# Padding line 008 to push this file over the --max-file-size used in tests
+# Padding line 009 to push this file over the --max-file-size used in tests
+# Padding line 010 to push this file over the --max-file-size used in tests
+# Padding line 011 to push this file over the --max-file-size used in tests
+# Padding line 012 to push this file over the --max-file-size used in tests
+# Padding line 013 to push this file over the --max-file-size used in tests
+# Padding line 014 to push this file over the --max-file-size used in tests
+# Padding line 015 to push this file over the --max-file-size used in tests
+# Padding line 016 to push this file over the --max-file-size used in tests
+# Padding line 017 to push this file over the --max-file-size used in tests
+# Padding line 018 to push this file over the --max-file-size used in tests
+# Padding line 019 to push this file over the --max-file-size used in tests
+# Padding line 020 to push this file over the --max-file-size used in tests
+# Padding line 021 to push this file over the --max-file-size used in tests
+# Padding line 022 to push this file over the --max-file-size used in tests
+# Padding line 023 to push this file over the --max-file-size used in tests
+# Padding line 024 to push this file over the --max-file-size used in tests
+# Padding line 025 to push this file over the --max-file-size used in tests
+# Padding line 026 to push this file over the --max-file-size used in tests
+# Padding line 027 to push this file over the --max-file-size used in tests
+# Padding line 028 to push this file over the --max-file-size used in tests
+# Padding line 029 to push this file over the --max-file-size used in tests
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
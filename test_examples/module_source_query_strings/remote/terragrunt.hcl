@@ -0,0 +1,3 @@
+terraform {
+  source = "git::https://example.com/org/repo.git//modules/app?ref=v1.2.3"
+}
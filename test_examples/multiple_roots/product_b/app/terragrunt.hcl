@@ -0,0 +1,11 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+dependency "network" {
+  config_path = "../../product_a/network"
+}
+
+inputs = {
+  vpc_id = dependency.network.outputs.vpc_id
+}
@@ -0,0 +1,18 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+locals {
+  extra_atlantis_dependencies = [
+    # Negated entries are moved after all others, so they exclude files from the whole list
+    "!../shared/generated/**",
+    "../shared/**",
+
+    # Negated paths are made relative to the module, the same as the other entries
+    "!${get_terragrunt_dir()}/backend.tf",
+  ]
+}
+
+inputs = {
+  foo = "bar"
+}
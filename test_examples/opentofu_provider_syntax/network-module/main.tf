@@ -0,0 +1,8 @@
+variable "vpc_arn" {
+  type = string
+}
+
+locals {
+  vpc_arn_parts = provider::aws::arn_parse(var.vpc_arn)
+  vpc_name      = templatestring("vpc-$${region}", { region = local.vpc_arn_parts.region })
+}
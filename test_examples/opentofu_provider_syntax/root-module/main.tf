@@ -0,0 +1,15 @@
+provider "aws" {
+  alias    = "by_region"
+  for_each = toset(["us-east-1", "eu-west-1"])
+  region   = each.key
+}
+
+resource "aws_vpc" "this" {
+  for_each   = toset(["us-east-1", "eu-west-1"])
+  provider   = aws.by_region[each.key]
+  cidr_block = "10.0.0.0/16"
+}
+
+module "network" {
+  source = "../network-module"
+}
@@ -0,0 +1,3 @@
+locals {
+  extra_atlantis_dependencies = ["./shared/*.tf"]
+}
@@ -0,0 +1,12 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+locals {
+  atlantis_apply_requirements = ["approved", "mergeable"]
+  atlantis_plan_requirements  = ["mergeable"]
+}
+
+inputs = {
+  foo = "bar"
+}
@@ -0,0 +1,11 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+dependency "mysql" {
+  config_path = "../../database/mysql"
+}
+
+inputs = {
+  foo = dependency.mysql.outputs.some_output
+}
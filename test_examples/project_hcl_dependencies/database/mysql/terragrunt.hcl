@@ -0,0 +1,11 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+dependency "vpc" {
+  config_path = "../../network/vpc"
+}
+
+inputs = {
+  foo = dependency.vpc.outputs.some_output
+}
@@ -0,0 +1,6 @@
+locals {
+  environment = "dns"
+
+  # Planned and applied last, although nothing depends on it
+  atlantis_execution_order_group = 5
+}
@@ -0,0 +1,12 @@
+locals {
+  common  = read_terragrunt_config("../common/common.hcl")
+  account = read_terragrunt_config(find_in_parent_folders("account.hcl"))
+  shared  = read_tfvars_file("${get_terragrunt_dir()}/../common/shared.tfvars")
+
+  # Depends on another local, so it can't be tracked
+  common_again = read_terragrunt_config("../common/${local.common.locals.name}.hcl")
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
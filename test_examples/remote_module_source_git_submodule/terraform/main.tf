@@ -0,0 +1,12 @@
+module "registry_submodule" {
+  source  = "registry.terraform.io/terraform-aws-modules/vpc/aws//modules/vpc-endpoints"
+  version = "3.7.0"
+}
+
+module "git_submodule" {
+  source = "git::https://github.com/transcend-io/terraform-aws-fargate-container.git//modules/sub?ref=v0.0.4"
+}
+
+module "local_module" {
+  source = "../terraform-module"
+}
@@ -0,0 +1,8 @@
+# https://developer.hashicorp.com/terraform/language/modules/sources#modules-in-package-sub-directories
+terraform {
+  source = "registry.terraform.io/terraform-aws-modules/vpc/aws//modules/vpc-endpoints?version=3.7.0"
+}
+
+inputs = {
+  foo = "bar"
+}
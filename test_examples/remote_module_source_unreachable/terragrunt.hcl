@@ -0,0 +1,8 @@
+# This host can never resolve, so the test fails if the source is ever fetched
+terraform {
+  source = "https://example.invalid/modules/vpc.zip//modules/vpc"
+}
+
+inputs = {
+  foo = "bar"
+}
@@ -0,0 +1,7 @@
+module "nested" {
+  source = "./nested"
+}
+
+module "shared" {
+  source = "../shared"
+}
@@ -0,0 +1,7 @@
+terraform {
+  source = "git::https://github.com/transcend-io/terraform-modules.git//modules/app?ref=v0.0.4"
+}
+
+inputs = {
+  foo = "bar"
+}
@@ -0,0 +1,8 @@
+locals {
+  atlantis_automerge          = true
+  atlantis_parallel           = false
+  atlantis_autoplan           = true
+  atlantis_workflow           = "repo-workflow"
+  atlantis_apply_requirements = ["approved", "mergeable"]
+  atlantis_terraform_version  = "1.5.7"
+}
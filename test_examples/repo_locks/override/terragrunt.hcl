@@ -0,0 +1,11 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+locals {
+  atlantis_repo_locks_mode = "on_plan"
+}
+
+inputs = {
+  foo = "bar"
+}
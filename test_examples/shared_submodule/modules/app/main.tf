@@ -0,0 +1,5 @@
+module "shared" {
+  source = "../shared"
+
+  name = "app"
+}
@@ -0,0 +1,5 @@
+locals {
+  extra_atlantis_dependencies = [
+    "../shared/versions.tf",
+  ]
+}
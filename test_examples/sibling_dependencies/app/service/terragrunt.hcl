@@ -0,0 +1,11 @@
+terraform {
+  source = "../../modules/service"
+}
+
+dependency "network" {
+  config_path = "../../app-network"
+}
+
+inputs = {
+  vpc_id = dependency.network.outputs.vpc_id
+}
@@ -0,0 +1,12 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+# Applied out-of-band, so kept in Terragrunt runs but left out of the Atlantis config
+locals {
+  atlantis_skip = true
+}
+
+inputs = {
+  foo = "bar"
+}
@@ -0,0 +1,10 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+# Skipped by Terragrunt runs, but still planned and applied through Atlantis
+skip = true
+
+inputs = {
+  foo = "bar"
+}
@@ -0,0 +1,11 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+dependency "app_base" {
+  config_path = "../../app_base"
+}
+
+inputs = {
+  foo = dependency.app_base.outputs.some_output
+}
@@ -0,0 +1,11 @@
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
+
+dependency "network" {
+  config_path = "../network"
+}
+
+inputs = {
+  foo = dependency.network.outputs.some_output
+}
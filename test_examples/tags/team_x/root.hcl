@@ -0,0 +1,3 @@
+locals {
+  atlantis_tags = ["team-x"]
+}
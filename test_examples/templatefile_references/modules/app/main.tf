@@ -0,0 +1,18 @@
+variable "name" {}
+
+variable "extra_config_path" {}
+
+resource "local_file" "config" {
+  filename = "config.yaml"
+  content  = templatefile("${path.module}/templates/config.tpl", { name = var.name })
+}
+
+resource "local_file" "policy" {
+  filename = "policy.json"
+  content  = file("policy.json")
+}
+
+resource "local_file" "extra_config" {
+  filename = "extra.yaml"
+  content  = file(var.extra_config_path)
+}
@@ -0,0 +1,19 @@
+locals {
+  atlantis_workflow_steps = {
+    plan = {
+      steps = [
+        "init",
+        { run = "terragrunt plan -out $PLANFILE" },
+      ]
+    }
+    apply = {
+      steps = [
+        { run = "terragrunt apply $PLANFILE" },
+      ]
+    }
+  }
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}
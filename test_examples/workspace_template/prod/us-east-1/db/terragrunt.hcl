@@ -0,0 +1,7 @@
+include "team" {
+  path = find_in_parent_folders("team.hcl")
+}
+
+terraform {
+  source = "git::git@github.com:transcend-io/terraform-aws-fargate-container?ref=v0.0.4"
+}